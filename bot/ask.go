@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/settings"
+)
+
+type GroqParams struct {
+	Model         string
+	MaxTokens     int
+	Temperature   float32
+	MessagesCount int
+	Instructions  string
+	Messages      []provider.Message
+}
+
+// clamp keeps the requested output within what the model can produce.
+func (params *GroqParams) clamp(model provider.Model) {
+	if model.MaxOutputTokens > 0 && params.MaxTokens > model.MaxOutputTokens {
+		params.MaxTokens = model.MaxOutputTokens
+	}
+	if model.ContextWindow > 0 && params.MaxTokens > model.ContextWindow {
+		params.MaxTokens = model.ContextWindow
+	}
+}
+
+func (params *GroqParams) request() provider.Request {
+	return provider.Request{
+		Model: params.Model,
+		Messages: append([]provider.Message{
+			{
+				Role:    provider.RoleSystem,
+				Content: params.Instructions,
+			},
+		}, params.Messages...),
+		MaxTokens:   params.MaxTokens,
+		Temperature: params.Temperature,
+	}
+}
+
+func askGroq(ctx context.Context, p provider.Provider, params *GroqParams) (*provider.Response, error) {
+	resp, err := p.ChatCompletion(ctx, params.request())
+	if err != nil {
+		fmt.Printf("error creating %v completion, %v\n", p.Name(), err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func askGroqStream(ctx context.Context, p provider.Provider, params *GroqParams, onDelta func(string)) (*provider.Response, error) {
+	resp, err := p.ChatCompletionStream(ctx, params.request(), onDelta)
+	if err != nil {
+		fmt.Printf("error streaming %v completion, %v\n", p.Name(), err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// candidate is a provider and model able to answer a message.
+type candidate struct {
+	provider provider.Provider
+	model    provider.Model
+}
+
+// candidates returns the provider and model selected for scope, followed by
+// the fallbacks to try when they fail.
+func (b *Bot) candidates(scope settings.Scope) []candidate {
+	p := b.scopeProvider(scope)
+	list := []candidate{{p, b.scopeModel(scope, p)}}
+
+	for _, entry := range strings.Split(b.settings.Get(context.Background(), scope, "fallbacks").String(), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		c, err := b.parseFallback(p, entry)
+		if err != nil {
+			log.Printf("guild %v: skipping fallback %v, %v", scope.GuildID, entry, err)
+			continue
+		}
+		duplicate := false
+		for _, other := range list {
+			duplicate = duplicate || (other.provider.Name() == c.provider.Name() && other.model.Name == c.model.Name)
+		}
+		if !duplicate {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// parseFallback resolves a fallback: "provider/model", a provider name for
+// its default model, or a model of the current provider.
+func (b *Bot) parseFallback(current provider.Provider, entry string) (candidate, error) {
+	p, name := current, entry
+	if prefix, rest, ok := strings.Cut(entry, "/"); ok {
+		if other, ok := b.providers[prefix]; ok {
+			p, name = other, rest
+		}
+	} else if other, ok := b.providers[entry]; ok {
+		p, name = other, other.DefaultModel()
+	}
+
+	if model, ok := provider.LookupModel(p.Name(), name); ok {
+		return candidate{p, model}, nil
+	}
+	if name == p.DefaultModel() {
+		return candidate{p, provider.Model{Provider: p.Name(), Name: name}}, nil
+	}
+	return candidate{}, fmt.Errorf("unknown model %v for provider %v", name, p.Name())
+}
+
+// ask gets an answer to m from the first candidate giving one, with history
+// fitted to each candidate's context window, and records the tokens it
+// used. With onDelta the answer is streamed, and once part of it was
+// streamed the candidate is not replaced.
+func (b *Bot) ask(ctx context.Context, m *discordgo.Message, candidates []candidate, base GroqParams, history conversation, onDelta func(string)) (string, error) {
+	var err error
+	for _, c := range candidates {
+		params := base
+		params.Model = c.model.Name
+		params.clamp(c.model)
+		params.Messages = history.messages
+		if c.model.ContextWindow > 0 {
+			var dropped int
+			params.Messages, dropped = history.fit(historyBudget(c.model, &params))
+			if dropped > 0 {
+				log.Printf("guild %v: dropped %v of %v messages to fit the %v context window", m.GuildID, dropped, len(history.messages), c.model.Name)
+			}
+		}
+
+		var resp *provider.Response
+		if onDelta == nil {
+			resp, err = b.complete(ctx, c.provider, &params, nil)
+		} else {
+			streamed := false
+			resp, err = b.complete(ctx, c.provider, &params, func(delta string) {
+				streamed = true
+				onDelta(delta)
+			})
+			if err != nil && streamed {
+				return "", err
+			}
+		}
+		if err == nil {
+			b.recordUsage(m, c, &params, resp)
+			return resp.Content, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+// complete asks p for a completion, streamed when onDelta is set, within the
+// configured request timeout.
+func (b *Bot) complete(ctx context.Context, p provider.Provider, params *GroqParams, onDelta func(string)) (*provider.Response, error) {
+	if b.config.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.config.RequestTimeout)
+		defer cancel()
+	}
+
+	if count, ok := b.inFlight[p.Name()]; ok {
+		count.Add(1)
+		defer count.Add(-1)
+	}
+
+	if onDelta == nil {
+		return askGroq(ctx, p, params)
+	}
+	return askGroqStream(ctx, p, params, onDelta)
+}
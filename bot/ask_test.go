@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/conneroisu/groq-go"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/provider/providertest"
+	"polynux/disgoroq/settings"
+)
+
+func newTestGroq(t *testing.T, replies ...providertest.Reply) (*provider.Groq, *providertest.Server) {
+	t.Helper()
+
+	server := providertest.NewServer(replies...)
+	t.Cleanup(server.Close)
+	p, err := provider.NewGroq("test-key", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, server
+}
+
+func testParams() *GroqParams {
+	return &GroqParams{
+		Model:        "llama-3.1-8b-instant",
+		MaxTokens:    100,
+		Temperature:  0.5,
+		Instructions: "Be brief",
+		Messages:     []provider.Message{{Role: provider.RoleUser, Content: "alice: hi"}},
+	}
+}
+
+// testMessage is the message answered by ask in tests.
+func testMessage() *discordgo.Message {
+	return &discordgo.Message{
+		GuildID:   testGuild,
+		ChannelID: testChannel,
+		Author:    &discordgo.User{ID: "alice"},
+	}
+}
+
+func TestAskGroq(t *testing.T) {
+	p, server := newTestGroq(t, providertest.Reply{Content: "hello alice"})
+
+	resp, err := askGroq(context.Background(), p, testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello alice" {
+		t.Errorf("got %q", resp.Content)
+	}
+
+	req := server.Requests()[0]
+	if req.Model != "llama-3.1-8b-instant" || req.MaxTokens != 100 {
+		t.Errorf("server got %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[0].Content != "Be brief" {
+		t.Errorf("server got messages %+v", req.Messages)
+	}
+}
+
+func TestAskGroqErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply providertest.Reply
+		check func(error) bool
+	}{
+		{"no choices", providertest.Reply{NoChoices: true}, func(err error) bool {
+			return errors.Is(err, provider.ErrNoChoices)
+		}},
+		{"rate limited", providertest.Reply{Status: http.StatusTooManyRequests, Error: "slow down", RetryAfter: "1"}, func(err error) bool {
+			var apiErr *groq.APIError
+			return errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusTooManyRequests
+		}},
+		{"server error", providertest.Reply{Status: http.StatusServiceUnavailable, Error: "down"}, func(err error) bool {
+			var apiErr *groq.APIError
+			return errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusServiceUnavailable
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestGroq(t, tt.reply)
+
+			resp, err := askGroq(context.Background(), p, testParams())
+			if !tt.check(err) || resp != nil {
+				t.Errorf("got %+v and error %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestAskGroqStream(t *testing.T) {
+	p, _ := newTestGroq(t, providertest.Reply{Deltas: []string{"hello", " alice"}})
+
+	var deltas []string
+	resp, err := askGroqStream(context.Background(), p, testParams(), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello alice" || strings.Join(deltas, "|") != "hello| alice" {
+		t.Errorf("got %q in deltas %q", resp.Content, deltas)
+	}
+}
+
+func TestMessageCreateWithGroq(t *testing.T) {
+	p, _ := newTestGroq(t, providertest.Reply{NoChoices: true}, providertest.Reply{Content: "hello alice"})
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "alice", "hi", testBotID))
+	b.messageCreate(s, s.post(testChannel, "alice", "hi again", testBotID))
+
+	if len(s.sent) != 2 {
+		t.Fatalf("sent %v messages, want 2", len(s.sent))
+	}
+	if !strings.Contains(s.sent[0].Content, "error") {
+		t.Errorf("answered %q to an empty completion", s.sent[0].Content)
+	}
+	if s.sent[1].Content != "hello alice" {
+		t.Errorf("answered %q", s.sent[1].Content)
+	}
+}
+
+func TestAskFailsOver(t *testing.T) {
+	p, server := newTestGroq(t,
+		providertest.Reply{Status: http.StatusServiceUnavailable, Error: "down"},
+		providertest.Reply{Content: "hello alice"},
+	)
+	b := newTestBot(t, p)
+	scope := settings.Guild(testGuild)
+	if _, err := b.settings.Set(context.Background(), scope, "fallbacks", "groq/llama3-70b-8192"); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := b.ask(context.Background(), testMessage(), b.candidates(scope), *testParams(), conversation{messages: testParams().Messages}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "hello alice" {
+		t.Errorf("got %q", response)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Model != "llama-3.1-8b-instant" || requests[1].Model != "llama3-70b-8192" {
+		t.Errorf("server got %+v", requests)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	scope := settings.Guild(testGuild)
+	if _, err := b.settings.Set(context.Background(), scope, "fallbacks", "llama3-70b-8192, groq, groq/llama3-8b-8192"); err != nil {
+		t.Fatal(err)
+	}
+
+	var models []string
+	for _, c := range b.candidates(scope) {
+		models = append(models, c.model.Name)
+	}
+	if got := strings.Join(models, ","); got != "llama-3.1-8b-instant,llama3-70b-8192,llama3-8b-8192" {
+		t.Errorf("candidates are %v", got)
+	}
+
+	if _, err := b.settings.Set(context.Background(), scope, "fallbacks", "openai/gpt-4o"); !errors.Is(err, settings.ErrInvalidValue) {
+		t.Errorf("accepted a fallback on an unconfigured provider, %v", err)
+	}
+}
+
+// blockingProvider answers only once its context is done.
+type blockingProvider struct {
+	fakeProvider
+	started chan struct{}
+}
+
+func (p *blockingProvider) ChatCompletion(ctx context.Context, req provider.Request) (*provider.Response, error) {
+	p.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestAskTimeout(t *testing.T) {
+	p := &blockingProvider{started: make(chan struct{}, 1)}
+	b := newTestBot(t, p)
+	b.config.RequestTimeout = 10 * time.Millisecond
+
+	_, err := b.ask(context.Background(), testMessage(), b.candidates(settings.Guild(testGuild)), *testParams(), conversation{}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+}
+
+func TestCloseAbortsRequestsInFlight(t *testing.T) {
+	p := &blockingProvider{started: make(chan struct{}, 1)}
+	b := newTestBot(t, p)
+
+	done := make(chan error)
+	go func() {
+		_, err := b.ask(b.ctx, testMessage(), b.candidates(settings.Guild(testGuild)), *testParams(), conversation{}, nil)
+		done <- err
+	}()
+
+	<-p.started
+	if count := b.InFlight()["groq"]; count != 1 {
+		t.Errorf("%v requests in flight, want 1", count)
+	}
+
+	b.Close()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if count := b.InFlight()["groq"]; count != 0 {
+		t.Errorf("%v requests still in flight", count)
+	}
+}
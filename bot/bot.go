@@ -0,0 +1,312 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/ratelimit"
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/store"
+	"polynux/disgoroq/trigger"
+	"polynux/disgoroq/usage"
+)
+
+type Config struct {
+	// Token is the Discord bot token.
+	Token string
+	// DefaultProvider answers for guilds that did not pick a provider.
+	DefaultProvider  string
+	SettingsCacheTTL time.Duration
+	// RequestTimeout bounds each completion asked to a provider, retries
+	// included. Zero means no timeout.
+	RequestTimeout time.Duration
+	// Quota caps the usage of every guild. Guilds can only lower it.
+	Quota usage.Quota
+}
+
+// Bot is a Discord bot answering with an LLM. Each Bot has its own session,
+// so several of them can run in the same process.
+type Bot struct {
+	config    Config
+	session   *discordgo.Session
+	store     store.Store
+	providers map[string]provider.Provider
+	settings  *settings.Settings
+	limiter   *ratelimit.Limiter
+	usage     *usage.Tracker
+	triggers  *trigger.Triggers
+	bursts    *burstCache
+
+	// ctx is canceled on shutdown, aborting the completions in flight.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inFlight map[string]*atomic.Int64
+
+	// mu guards closing, so no reply starts once Shutdown waits for them.
+	mu      sync.Mutex
+	closing bool
+	replies sync.WaitGroup
+}
+
+func New(config Config, st store.Store, providers map[string]provider.Provider) (*Bot, error) {
+	if _, ok := providers[config.DefaultProvider]; !ok {
+		return nil, fmt.Errorf("default provider %q is not configured", config.DefaultProvider)
+	}
+
+	session, err := discordgo.New("Bot " + config.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bot{
+		config:    config,
+		session:   session,
+		store:     st,
+		providers: providers,
+		limiter:   ratelimit.New(),
+		usage:     usage.NewTracker(st, config.SettingsCacheTTL),
+		triggers:  trigger.New(st, config.SettingsCacheTTL),
+		bursts:    newBurstCache(),
+		inFlight:  make(map[string]*atomic.Int64, len(providers)),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	for name := range providers {
+		b.inFlight[name] = &atomic.Int64{}
+	}
+	b.settings = settings.New(b.settingsRegistry(), st, config.SettingsCacheTTL)
+
+	session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		b.messageCreate(discordSession{s}, m)
+	})
+	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		b.interactionCreate(discordSession{s}, i)
+	})
+
+	session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsGuilds
+
+	return b, nil
+}
+
+// Open connects to Discord and registers the slash commands.
+func (b *Bot) Open() error {
+	if err := b.session.Open(); err != nil {
+		return err
+	}
+	_, err := b.session.ApplicationCommandBulkOverwrite(b.session.State.User.ID, "", commands)
+	if err != nil {
+		b.session.Close()
+		return fmt.Errorf("creating commands: %w", err)
+	}
+	return nil
+}
+
+// Shutdown stops answering new messages and waits for the replies in
+// progress, aborting them once ctx is done. It then flushes the store and
+// disconnects from Discord.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.closing = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.replies.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("aborting replies in progress, requests in flight: %v", b.InFlight())
+		b.cancel()
+		<-done
+	}
+	b.cancel()
+
+	if err := b.store.Flush(context.Background()); err != nil {
+		log.Println("error flushing store,", err)
+	}
+	return b.session.Close()
+}
+
+// Close shuts down without waiting for the replies in progress.
+func (b *Bot) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return b.Shutdown(ctx)
+}
+
+// startReply registers a reply about to be made, and reports false when the
+// bot is shutting down and must not make any.
+func (b *Bot) startReply() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closing {
+		return false
+	}
+	b.replies.Add(1)
+	return true
+}
+
+// InFlight returns the number of completions currently asked to each
+// provider.
+func (b *Bot) InFlight() map[string]int64 {
+	counts := make(map[string]int64, len(b.inFlight))
+	for name, count := range b.inFlight {
+		counts[name] = count.Load()
+	}
+	return counts
+}
+
+func (b *Bot) interactionCreate(s Session, i *discordgo.InteractionCreate) {
+	handlers := b.commandHandlers()
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		handlers = b.autocompleteHandlers()
+	default:
+		return
+	}
+	handler, ok := handlers[i.ApplicationCommandData().Name]
+	if !ok {
+		return
+	}
+	handler(s, i)
+}
+
+func getMessages(s Session, channelID string, num int) ([]*discordgo.Message, error) {
+	if num <= 100 {
+		messages, err := s.ChannelMessages(channelID, num, "", "", "")
+		if err != nil {
+			log.Println("error getting messages,", err)
+			return nil, err
+		}
+		return messages, nil
+	}
+
+	messages := []*discordgo.Message{}
+	for num > 0 {
+		var toGet int
+		if num > 100 {
+			toGet = 100
+		} else {
+			toGet = num
+		}
+		lastMessage := ""
+		if len(messages) > 0 {
+			lastMessage = messages[len(messages)-1].ID
+		}
+		newMessages, err := s.ChannelMessages(channelID, toGet, lastMessage, "", "")
+		if err != nil {
+			fmt.Println("error getting messages,", err)
+			return nil, err
+		}
+		messages = append(messages, newMessages...)
+		num -= toGet
+		if len(newMessages) < toGet {
+			// Reached the beginning of the channel.
+			break
+		}
+	}
+	return messages, nil
+}
+
+// botMentioned reports whether m is addressed to the bot: it mentions the
+// bot or replies to one of its messages.
+func botMentioned(s Session, m *discordgo.MessageCreate) bool {
+	for i := range m.Mentions {
+		if m.Mentions[i].ID == s.BotUserID() {
+			return true
+		}
+	}
+	referenced := referencedMessage(s, m.Message)
+	return referenced != nil && referenced.Author != nil && referenced.Author.ID == s.BotUserID()
+}
+
+func (b *Bot) messageCreate(s Session, m *discordgo.MessageCreate) {
+	if m.Author.ID == s.BotUserID() {
+		return
+	}
+	if !b.startReply() {
+		return
+	}
+	defer b.replies.Done()
+
+	ctx := b.ctx
+	scope := channelScope(s, m.GuildID, m.ChannelID)
+
+	if !b.settings.Get(ctx, scope, "state").Bool() {
+		return
+	}
+
+	// Computed once, as telling a reply to the bot may take a request.
+	mentioned := botMentioned(s, m)
+	if !mentioned && rand.Float64() > b.probability(ctx, s, scope, m) {
+		return
+	}
+
+	if !b.allowReply(ctx, s, scope, m, mentioned) {
+		return
+	}
+
+	if !b.allowQuota(ctx, s, m, mentioned) {
+		return
+	}
+
+	messageCount := b.settings.Get(ctx, scope, "messagescount").Int()
+	messages, err := getMessages(s, m.ChannelID, messageCount)
+	if err != nil {
+		fmt.Println("error getting messages,", err)
+		return
+	}
+
+	params := GroqParams{
+		MaxTokens:     b.settings.Get(ctx, scope, "maxtokens").Int(),
+		Temperature:   float32(b.settings.Get(ctx, scope, "temperature").Float()),
+		MessagesCount: messageCount,
+		Instructions:  b.settings.Get(ctx, scope, "prompt").String(),
+	}
+
+	candidates := b.candidates(scope)
+	history := buildConversation(s.BotUserID(), messages, repliedTo(s, m.Message))
+
+	reference := &discordgo.MessageReference{
+		MessageID: m.ID,
+		ChannelID: m.ChannelID,
+		GuildID:   m.GuildID,
+	}
+
+	if b.settings.Get(ctx, scope, "streaming").Bool() {
+		streamReply(s, m.ChannelID, reference, func(onDelta func(string)) (string, error) {
+			return b.ask(ctx, m.Message, candidates, params, history, onDelta)
+		})
+		return
+	}
+
+	response, err := b.ask(ctx, m.Message, candidates, params, history, nil)
+	if err != nil {
+		if mentioned {
+			s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+				Content:   "There was an error getting the response.",
+				Reference: reference,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			})
+		} else {
+			s.ChannelMessageSend(m.ChannelID, "There was an error getting the response.")
+		}
+		return
+	}
+	if err := newReplyChain(s, m.ChannelID, reference).update(response); err != nil {
+		log.Println("error sending response,", err)
+	}
+}
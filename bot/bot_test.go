@@ -0,0 +1,577 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/store"
+	"polynux/disgoroq/trigger"
+	"polynux/disgoroq/usage"
+)
+
+// fakeProvider answers every completion with content, streamed as deltas
+// when set, or fails with err.
+type fakeProvider struct {
+	mu sync.Mutex
+
+	content string
+	deltas  []string
+	err     error
+
+	requests []provider.Request
+}
+
+func (p *fakeProvider) Name() string {
+	return "groq"
+}
+
+func (p *fakeProvider) DefaultModel() string {
+	return "llama-3.1-8b-instant"
+}
+
+func (p *fakeProvider) ChatCompletion(ctx context.Context, req provider.Request) (*provider.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &provider.Response{Model: req.Model, Content: p.content}, nil
+}
+
+func (p *fakeProvider) ChatCompletionStream(ctx context.Context, req provider.Request, onDelta func(string)) (*provider.Response, error) {
+	resp, err := p.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, delta := range p.deltas {
+		onDelta(delta)
+	}
+	return resp, nil
+}
+
+func (p *fakeProvider) lastRequest() provider.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.requests[len(p.requests)-1]
+}
+
+func newTestBot(t *testing.T, p provider.Provider) *Bot {
+	t.Helper()
+
+	b, err := New(Config{
+		Token:            "test",
+		DefaultProvider:  "groq",
+		SettingsCacheTTL: time.Minute,
+	}, store.NewMemory(), map[string]provider.Provider{"groq": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// enable turns the bot on and makes it answer every message.
+func enable(t *testing.T, b *Bot) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := b.settings.SetBool(ctx, settings.Guild(testGuild), "state", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.settings.SetFloat(ctx, settings.Guild(testGuild), "threshold", 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewRequiresDefaultProvider(t *testing.T) {
+	_, err := New(Config{DefaultProvider: "openai"}, store.NewMemory(), map[string]provider.Provider{"groq": &fakeProvider{}})
+	if err == nil {
+		t.Fatal("New accepted a default provider that is not configured")
+	}
+}
+
+func TestGetMessagesPagination(t *testing.T) {
+	s := newFakeSession()
+	for n := 0; n < 250; n++ {
+		s.post(testChannel, "user", strconv.Itoa(n))
+	}
+
+	messages, err := getMessages(s, testChannel, 230)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 230 {
+		t.Fatalf("got %v messages, want 230", len(messages))
+	}
+	for idx, m := range messages {
+		if want := strconv.Itoa(249 - idx); m.Content != want {
+			t.Fatalf("message %v is %q, want %q", idx, m.Content, want)
+		}
+	}
+
+	want := []messagesRequest{
+		{testChannel, 100, ""},
+		{testChannel, 100, messages[99].ID},
+		{testChannel, 30, messages[199].ID},
+	}
+	if len(s.messagesRequests) != len(want) {
+		t.Fatalf("got %v requests, want %v", s.messagesRequests, want)
+	}
+	for idx := range want {
+		if s.messagesRequests[idx] != want[idx] {
+			t.Errorf("request %v is %+v, want %+v", idx, s.messagesRequests[idx], want[idx])
+		}
+	}
+}
+
+func TestGetMessagesStopsAtChannelStart(t *testing.T) {
+	s := newFakeSession()
+	for n := 0; n < 120; n++ {
+		s.post(testChannel, "user", strconv.Itoa(n))
+	}
+
+	messages, err := getMessages(s, testChannel, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 120 {
+		t.Fatalf("got %v messages, want 120", len(messages))
+	}
+	if len(s.messagesRequests) != 2 {
+		t.Errorf("got %v requests, want 2", len(s.messagesRequests))
+	}
+}
+
+func TestMessageCreateIgnoresOwnMessages(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, testBotID, "hi"))
+
+	if len(p.requests) != 0 || len(s.sent) != 0 {
+		t.Fatal("the bot answered its own message")
+	}
+}
+
+func TestMessageCreateDisabled(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi", testBotID))
+
+	if len(p.requests) != 0 || len(s.sent) != 0 {
+		t.Fatal("the bot answered while turned off")
+	}
+}
+
+func TestMessageCreateThreshold(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	if _, err := b.settings.SetFloat(context.Background(), settings.Guild(testGuild), "threshold", 0); err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi"))
+	if len(p.requests) != 0 {
+		t.Fatal("the bot answered with a threshold of 0")
+	}
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi", testBotID))
+	if len(p.requests) != 1 {
+		t.Fatal("the bot did not answer a mention")
+	}
+}
+
+func TestMessageCreateTriggers(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"threshold": "0", "ratelimit_user": "off", "ratelimit_channel": "off", "ratelimit_guild": "off"})
+	ctx := context.Background()
+	for _, tr := range []store.Trigger{
+		{GuildID: testGuild, Kind: trigger.Alias, Pattern: "groqy", Probability: 1},
+		{GuildID: testGuild, Kind: trigger.Keyword, Pattern: "pizza", Probability: 0},
+	} {
+		if _, err := b.triggers.Add(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "alice", "pizza time"))
+	b.messageCreate(s, s.post(testChannel, "alice", "hey Groqy!"))
+	if len(p.requests) != 1 {
+		t.Fatalf("asked the provider %v times, want only for the alias", len(p.requests))
+	}
+}
+
+func TestMessageCreateReplyToBot(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"threshold": "0", "messagescount": "2"})
+	s := newFakeSession()
+
+	question := s.post(testChannel, "alice", "what is the capital of France?")
+	answer := s.reply(question.Message, testBotID, "Paris, obviously")
+	s.post(testChannel, "bob", "lunch?")
+	s.post(testChannel, "carol", "yes")
+	b.messageCreate(s, s.post(testChannel, "dave", "whatever"))
+
+	b.messageCreate(s, s.reply(answer.Message, "alice", "are you sure?"))
+	if len(p.requests) != 1 {
+		t.Fatal("the bot did not answer a reply to its message")
+	}
+	var history []string
+	for _, m := range p.lastRequest().Messages[1:] {
+		history = append(history, m.Content)
+	}
+	want := "alice: what is the capital of France?|Paris, obviously|dave: whatever|alice: are you sure?"
+	if got := strings.Join(history, "|"); got != want {
+		t.Errorf("history is %q, want %q", got, want)
+	}
+
+	b.messageCreate(s, s.reply(question.Message, "bob", "Lyon"))
+	if len(p.requests) != 1 {
+		t.Error("the bot answered a reply to someone else")
+	}
+}
+
+func TestMessageCreateReplyToDeletedMessage(t *testing.T) {
+	p := &fakeProvider{err: errors.New("provider down")}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+
+	// Discord leaves out the replied-to message once it is deleted.
+	m := s.post(testChannel, "alice", "what about this?")
+	m.Type = discordgo.MessageTypeReply
+	m.MessageReference = &discordgo.MessageReference{MessageID: "deleted", ChannelID: testChannel, GuildID: testGuild}
+	b.messageCreate(s, m)
+
+	if len(p.requests) != 1 {
+		t.Fatalf("asked the provider %v times, want 1", len(p.requests))
+	}
+	// Once to tell whether it replies to the bot, once for the reply chain.
+	if len(s.messageRequests) > 2 {
+		t.Errorf("fetched the deleted message %v times", len(s.messageRequests))
+	}
+	if len(s.sent) != 1 || s.sent[0].Reference != nil {
+		t.Errorf("sent %+v, want an error message not replying to a mention", s.sent)
+	}
+}
+
+func TestMessageCreateReplies(t *testing.T) {
+	p := &fakeProvider{content: "hello there"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+	s.post(testChannel, testBotID, "earlier answer")
+	m := s.post(testChannel, "alice", "hi bot")
+
+	b.messageCreate(s, m)
+
+	req := p.lastRequest()
+	if req.Model != "llama-3.1-8b-instant" {
+		t.Errorf("request model is %q", req.Model)
+	}
+	if len(req.Messages) != 3 || req.Messages[0].Role != provider.RoleSystem {
+		t.Fatalf("unexpected request messages %+v", req.Messages)
+	}
+	if got := req.Messages[1]; got.Role != provider.RoleAssistant || got.Content != "earlier answer" {
+		t.Errorf("bot message became %+v", got)
+	}
+	if got := req.Messages[2]; got.Role != provider.RoleUser || !strings.Contains(got.Content, "hi bot") {
+		t.Errorf("user message became %+v", got)
+	}
+
+	if len(s.sent) != 1 {
+		t.Fatalf("sent %v messages, want 1", len(s.sent))
+	}
+	sent := s.sent[0]
+	if sent.Content != "hello there" || sent.Reference == nil || sent.Reference.MessageID != m.ID {
+		t.Errorf("sent %+v, want a reply to %v", sent.MessageSend, m.ID)
+	}
+}
+
+func setSettings(t *testing.T, b *Bot, values map[string]string) {
+	t.Helper()
+
+	for name, value := range values {
+		if _, err := b.settings.Set(context.Background(), settings.Guild(testGuild), name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMessageCreateUserRateLimit(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"ratelimit_user": "1/1h", "ratelimit_channel": "off", "ratelimit_guild": "off"})
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "spammer", "one"))
+	b.messageCreate(s, s.post(testChannel, "spammer", "two"))
+	if len(p.requests) != 1 || len(s.sent) != 1 {
+		t.Fatalf("asked the provider %v times and sent %+v", len(p.requests), s.sent)
+	}
+
+	b.messageCreate(s, s.post(testChannel, "alice", "three"))
+	if len(p.requests) != 2 {
+		t.Fatal("one user's limit locked out another user")
+	}
+
+	b.messageCreate(s, s.post(testChannel, "spammer", "four", testBotID))
+	b.messageCreate(s, s.post(testChannel, "spammer", "five", testBotID))
+	if len(p.requests) != 2 {
+		t.Fatal("a mention bypassed its author's limit")
+	}
+	if len(s.sent) != 3 || !strings.HasPrefix(s.sent[2].Content, "Please wait") {
+		t.Fatalf("sent %+v, want a single wait notice", s.sent)
+	}
+}
+
+func TestMessageCreateChannelRateLimit(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"ratelimit_user": "off", "ratelimit_channel": "1/1h"})
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "alice", "one"))
+	b.messageCreate(s, s.post(testChannel, "bob", "two"))
+	if len(p.requests) != 1 {
+		t.Fatalf("asked the provider %v times, want 1", len(p.requests))
+	}
+
+	b.messageCreate(s, s.post("other", "bob", "three"))
+	b.messageCreate(s, s.post(testChannel, "bob", "four", testBotID))
+	if len(p.requests) != 3 {
+		t.Fatal("the channel limit applied to another channel or to a mention")
+	}
+	if len(s.sent) != 3 {
+		t.Errorf("sent %+v, want only answers", s.sent)
+	}
+}
+
+func TestMessageCreateQuota(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	b.config.Quota = usage.Quota{DailyTokens: 1_000_000}
+	enable(t, b)
+	setSettings(t, b, map[string]string{"ratelimit_user": "off", "ratelimit_channel": "off", "ratelimit_guild": "off"})
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "alice", "hi"))
+	day, _, err := b.usage.Totals(context.Background(), testGuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Requests != 1 || day.PromptTokens == 0 || day.CompletionTokens == 0 {
+		t.Fatalf("recorded %+v, want estimated tokens", day)
+	}
+
+	if _, err := b.settings.Set(context.Background(), settings.Channel(testGuild, testChannel), "quota_daily_tokens", "1"); !errors.Is(err, settings.ErrInvalidValue) {
+		t.Errorf("accepted a channel quota, %v", err)
+	}
+	setSettings(t, b, map[string]string{"quota_daily_tokens": strconv.Itoa(day.Tokens())})
+
+	b.messageCreate(s, s.post(testChannel, "alice", "hi again"))
+	b.messageCreate(s, s.post(testChannel, "alice", "hello?", testBotID))
+	b.messageCreate(s, s.post(testChannel, "alice", "hello??", testBotID))
+	if len(p.requests) != 1 {
+		t.Fatalf("asked the provider %v times over quota", len(p.requests))
+	}
+	if len(s.sent) != 2 || !strings.Contains(s.sent[1].Content, "daily quota") {
+		t.Fatalf("sent %+v, want a single quota notice", s.sent)
+	}
+}
+
+func TestMessageCreateProviderError(t *testing.T) {
+	p := &fakeProvider{err: errors.New("boom")}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+	m := s.post(testChannel, "user", "hi", testBotID)
+
+	b.messageCreate(s, m)
+
+	if len(s.sent) != 1 {
+		t.Fatalf("sent %v messages, want 1", len(s.sent))
+	}
+	if sent := s.sent[0]; !strings.Contains(sent.Content, "error") || sent.Reference == nil {
+		t.Errorf("sent %+v, want an error reply", sent.MessageSend)
+	}
+}
+
+func TestMessageCreateSendError(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+	s.sendErr = errFakeSend
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi"))
+
+	if len(p.requests) != 1 {
+		t.Fatal("the provider was not asked")
+	}
+}
+
+func TestMessageCreateStreaming(t *testing.T) {
+	p := &fakeProvider{content: "hello there", deltas: []string{"hello", " there"}}
+	b := newTestBot(t, p)
+	enable(t, b)
+	if _, err := b.settings.SetBool(context.Background(), settings.Guild(testGuild), "streaming", true); err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeSession()
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi"))
+
+	if len(s.sent) != 1 || s.sent[0].Content != streamPlaceholder {
+		t.Fatalf("sent %+v, want the placeholder", s.sent)
+	}
+	if len(s.edits) == 0 || *s.edits[len(s.edits)-1].Content != "hello there" {
+		t.Fatalf("the placeholder was not edited into the answer")
+	}
+}
+
+func TestReplyChainShrinks(t *testing.T) {
+	s := newFakeSession()
+	reference := &discordgo.MessageReference{MessageID: "question", ChannelID: testChannel, GuildID: testGuild}
+	reply := newReplyChain(s, testChannel, reference)
+
+	// A stream grows over three messages, then fails and is replaced by the
+	// error message.
+	if err := reply.update(strings.Repeat("word ", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sent) != 3 {
+		t.Fatalf("sent %v messages, want 3", len(s.sent))
+	}
+	ids := reply.ids
+	if err := reply.update("There was an error getting the response."); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.deleted) != 2 || s.deleted[0] != ids[2] || s.deleted[1] != ids[1] {
+		t.Errorf("deleted %v, want the last two of %v", s.deleted, ids)
+	}
+	if len(reply.ids) != 1 || *s.edits[len(s.edits)-1].Content != "There was an error getting the response." {
+		t.Errorf("the first message does not show the error")
+	}
+
+	// Growing again sends new messages.
+	if err := reply.update(strings.Repeat("word ", 500)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sent) != 4 || len(reply.ids) != 2 {
+		t.Errorf("sent %v messages for %v chunks", len(s.sent), len(reply.ids))
+	}
+}
+
+func TestMessageCreateChannelOverride(t *testing.T) {
+	p := &fakeProvider{content: "hello"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+	s.channels[testChannel] = &discordgo.Channel{ID: testChannel, ParentID: "category"}
+	_, err := b.settings.Set(context.Background(), settings.Channel(testGuild, "category"), "state", "off")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.messageCreate(s, s.post(testChannel, "user", "hi", testBotID))
+
+	if len(p.requests) != 0 {
+		t.Fatal("the bot answered in a category it is turned off in")
+	}
+}
+
+// gatedProvider answers once released, or fails when its context is done.
+type gatedProvider struct {
+	fakeProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedProvider(content string) *gatedProvider {
+	return &gatedProvider{
+		fakeProvider: fakeProvider{content: content},
+		started:      make(chan struct{}, 1),
+		release:      make(chan struct{}),
+	}
+}
+
+func (p *gatedProvider) ChatCompletion(ctx context.Context, req provider.Request) (*provider.Response, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+		return p.fakeProvider.ChatCompletion(ctx, req)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestShutdownWaitsForReplies(t *testing.T) {
+	p := newGatedProvider("hello")
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+
+	go b.messageCreate(s, s.post(testChannel, "user", "hi"))
+	<-p.started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- b.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("shut down before the reply was sent")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.messageCreate(s, s.post(testChannel, "user", "late", testBotID))
+
+	close(p.release)
+	<-shutdown
+	if len(s.sent) != 1 || s.sent[0].Content != "hello" {
+		t.Errorf("sent %+v, want only the reply in progress", s.sent)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	p := newGatedProvider("hello")
+	b := newTestBot(t, p)
+	enable(t, b)
+	s := newFakeSession()
+
+	go b.messageCreate(s, s.post(testChannel, "user", "hi"))
+	<-p.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	b.Shutdown(ctx)
+
+	if len(p.requests) != 0 {
+		t.Error("the reply in progress was not aborted")
+	}
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageLength is the largest message content Discord accepts.
+const maxMessageLength = 2000
+
+const fenceMarker = "```"
+
+// maxFenceLength bounds the fence reopened at the start of a chunk: the
+// marker and a language name, never a whole line of the model's output.
+const maxFenceLength = 24
+
+// chunkSeparators are the preferred places to split a message, from the
+// most to the least natural.
+var chunkSeparators = []string{"\n\n", "\n", ". ", "! ", "? ", " "}
+
+// splitMessage cuts content into chunks of at most limit bytes, preferring
+// paragraph, line, sentence and word boundaries. A code block cut in two is
+// closed at the end of a chunk and reopened with the same fence at the start
+// of the next one.
+func splitMessage(content string, limit int) []string {
+	chunks := []string{}
+	fence := ""
+	window := limit - len("\n"+fenceMarker)
+	for content != "" {
+		// A fence is reopened only when the chunk still has room for some of
+		// the content after it, so that every chunk takes at least a rune of
+		// what is left and the loop ends.
+		reopened := 0
+		if fence != "" && len(fence)+len("\n")+utf8.UTFMax < window {
+			content = fence + "\n" + content
+			reopened = len(fence) + len("\n")
+		}
+		if len(content) <= limit {
+			chunks = append(chunks, content)
+			break
+		}
+
+		end, next := splitPoint(content, window, reopened)
+		chunk := strings.TrimRight(content[:end], " \n")
+		content = strings.TrimLeft(content[next:], "\n")
+
+		fence = openFence(chunk)
+		if fence != "" {
+			chunk += "\n" + fenceMarker
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// splitPoint returns where the chunk fitting in window should end and where
+// the rest of content starts. The rest always starts after minEnd so that a
+// reopened fence is never the only thing in a chunk.
+func splitPoint(content string, window, minEnd int) (int, int) {
+	head := content[:window]
+	for _, sep := range chunkSeparators {
+		idx := strings.LastIndex(head, sep)
+		if idx >= window/2 && idx >= minEnd {
+			if sep == "\n\n" || sep == "\n" {
+				return idx, idx + len(sep)
+			}
+			// keep the punctuation with the sentence it ends
+			return idx + len(sep) - 1, idx + len(sep)
+		}
+	}
+	for window > 0 && !utf8.RuneStart(content[window]) {
+		window--
+	}
+	return window, window
+}
+
+// openFence returns the fence of a code block left open at the end of chunk,
+// or an empty string when every block is closed.
+func openFence(chunk string) string {
+	fence := ""
+	for _, line := range strings.Split(chunk, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, fenceMarker) {
+			continue
+		}
+		if fence == "" {
+			fence = fenceOf(trimmed)
+		} else {
+			fence = ""
+		}
+	}
+	return fence
+}
+
+// fenceOf returns the marker and language name opening a code block on
+// line. Anything longer than maxFenceLength is not a language name, and only
+// the marker is kept.
+func fenceOf(line string) string {
+	if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		line = line[:idx]
+	}
+	if len(line) > maxFenceLength {
+		return fenceMarker
+	}
+	return line
+}
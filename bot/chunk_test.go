@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkChunks fails t when a chunk is empty, longer than limit or not valid
+// UTF-8.
+func checkChunks(t *testing.T, chunks []string, limit int) {
+	t.Helper()
+	for idx, chunk := range chunks {
+		if chunk == "" {
+			t.Errorf("chunk %v is empty", idx)
+		}
+		if len(chunk) > limit {
+			t.Errorf("chunk %v is %v bytes, over %v", idx, len(chunk), limit)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %v cuts a rune: %q", idx, chunk)
+		}
+	}
+}
+
+func TestSplitMessageShort(t *testing.T) {
+	chunks := splitMessage("hello", maxMessageLength)
+	if len(chunks) != 1 || chunks[0] != "hello" {
+		t.Errorf("got %q", chunks)
+	}
+	if chunks := splitMessage("", maxMessageLength); len(chunks) != 0 {
+		t.Errorf("got %q for an empty message", chunks)
+	}
+}
+
+func TestSplitMessageLimit(t *testing.T) {
+	content := strings.Repeat("word ", 1000)
+	chunks := splitMessage(content, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+	if len(chunks) != 3 {
+		t.Errorf("got %v chunks, want 3", len(chunks))
+	}
+	if got := strings.Join(chunks, " "); got != content {
+		t.Error("lost words between chunks")
+	}
+
+	exact := strings.Repeat("a", maxMessageLength)
+	if chunks := splitMessage(exact, maxMessageLength); len(chunks) != 1 {
+		t.Errorf("got %v chunks for a message of exactly the limit", len(chunks))
+	}
+}
+
+func TestSplitMessageSeparators(t *testing.T) {
+	paragraph := strings.Repeat("a", 1200)
+	chunks := splitMessage(paragraph+"\n\n"+paragraph, maxMessageLength)
+	if len(chunks) != 2 || chunks[0] != paragraph || chunks[1] != paragraph {
+		t.Errorf("did not split between paragraphs: %q", chunks)
+	}
+
+	sentence := strings.Repeat("b", 1200) + "."
+	chunks = splitMessage(sentence+" "+sentence, maxMessageLength)
+	if len(chunks) != 2 || chunks[0] != sentence {
+		t.Errorf("did not keep the period with its sentence: %q", chunks)
+	}
+}
+
+func TestSplitMessageMultibyte(t *testing.T) {
+	// Neither the separators nor the limit fall between runes.
+	content := strings.Repeat("é", 1500) + strings.Repeat("日本語", 500)
+	chunks := splitMessage(content, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+	if got := strings.Join(chunks, ""); got != content {
+		t.Error("lost runes between chunks")
+	}
+}
+
+func TestSplitMessageReopensFence(t *testing.T) {
+	code := strings.Repeat("fmt.Println(\"hello\")\n", 150)
+	content := "Here you go:\n```go\n" + code + "```\nDone."
+	chunks := splitMessage(content, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+	if len(chunks) < 2 {
+		t.Fatalf("got %v chunks", len(chunks))
+	}
+	for idx, chunk := range chunks {
+		if strings.Count(chunk, fenceMarker)%2 != 0 {
+			t.Errorf("chunk %v leaves a code block open: %q", idx, chunk)
+		}
+		if idx > 0 && !strings.HasPrefix(chunk, "```go\n") {
+			t.Errorf("chunk %v does not reopen the code block: %q", idx, chunk[:20])
+		}
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "```\nDone.") {
+		t.Errorf("last chunk does not end the message: %q", chunks[len(chunks)-1])
+	}
+}
+
+func TestSplitMessageLongFenceLine(t *testing.T) {
+	// A fence line longer than a chunk is not reopened whole.
+	content := fenceMarker + strings.Repeat("x", 5000)
+	chunks := splitMessage(content, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+	if len(chunks) != 3 {
+		t.Fatalf("got %v chunks, want 3", len(chunks))
+	}
+	for idx, chunk := range chunks[1:] {
+		if !strings.HasPrefix(chunk, fenceMarker+"\n") {
+			t.Errorf("chunk %v does not reopen the code block: %q", idx+1, chunk[:20])
+		}
+	}
+
+	content = fenceMarker + "go " + strings.Repeat("y", 5000)
+	chunks = splitMessage(content, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+	if len(chunks) != 3 || !strings.HasPrefix(chunks[1], "```go\n") {
+		t.Errorf("did not keep the language of the code block: %v chunks", len(chunks))
+	}
+}
+
+func TestSplitMessageSmallLimit(t *testing.T) {
+	// Even a limit leaving no room for a reopened fence makes progress.
+	content := fenceMarker + strings.Repeat("z", 100)
+	chunks := splitMessage(content, 12)
+	checkChunks(t, chunks, 12)
+	if len(chunks) == 0 || len(chunks) > len(content) {
+		t.Errorf("got %v chunks", len(chunks))
+	}
+}
+
+func TestOpenFence(t *testing.T) {
+	tests := []struct {
+		chunk, want string
+	}{
+		{"no code", ""},
+		{"```go\ncode", "```go"},
+		{"```go\ncode\n```", ""},
+		{"  ```python  \ncode", "```python"},
+		{"```js some title\ncode", "```js"},
+		{"```" + strings.Repeat("x", 100), "```"},
+	}
+	for _, tt := range tests {
+		if got := openFence(tt.chunk); got != tt.want {
+			t.Errorf("openFence(%q) = %q, want %q", tt.chunk, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/trigger"
+)
+
+var (
+	defaultMemberPermissions int64 = discordgo.PermissionManageMessages
+
+	commands = []*discordgo.ApplicationCommand{
+		{
+			Name:        "ping",
+			Description: "Replies with Pong!",
+		},
+		{
+			Name:        "temperature",
+			Description: "Set the temperature for the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					Name:        "temperature",
+					Description: "The temperature for the bot (0.0-1.0)",
+					Required:    true,
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:                     "toggle",
+			Description:              "Toggle the bot on or off",
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:                     "streaming",
+			Description:              "Toggle streaming replies that update as they are generated",
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "threshold",
+			Description: "Set the threshold for the bot (activation probability; 0.0-1.0)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					Name:        "threshold",
+					Description: "The threshold activation (0.0-1.0)",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "messagescount",
+			Description: "Set the number of messages to consider for the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "messagescount",
+					Description: "The number of messages to consider for the bot (1-100)",
+					Required:    true,
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "provider",
+			Description: "Set the LLM provider for the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "provider",
+					Description: "The provider to use",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Groq", Value: "groq"},
+						{Name: "OpenAI-compatible", Value: "openai"},
+					},
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "model",
+			Description: "Set the model used by the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "model",
+					Description:  "The model to use with the current provider",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "maxtokens",
+			Description: "Set the maximum number of tokens the bot may answer with",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "maxtokens",
+					Description: "The maximum number of tokens per answer (limited by the model)",
+					Required:    true,
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:                     "clean",
+			Description:              "Clean the bot's messages",
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "trigger",
+			Description: "Manage the rules making the bot answer without a mention",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "add",
+					Description: "Add a trigger",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "kind",
+							Description: "What the trigger matches",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Keyword", Value: trigger.Keyword},
+								{Name: "Regular expression", Value: trigger.Regex},
+								{Name: "Name of the bot", Value: trigger.Alias},
+							},
+						},
+						{
+							Name:        "pattern",
+							Description: "The keyword, regular expression or name to match",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+							MaxLength:   trigger.MaxPatternLength,
+						},
+						{
+							Name:        "probability",
+							Description: "Probability to answer a matching message (0.0-1.0), 1 by default",
+							Type:        discordgo.ApplicationCommandOptionNumber,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "Remove a trigger",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "id",
+							Description: "The trigger number, as shown by /trigger list",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "list",
+					Description: "List the triggers of the server",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+			},
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:                     "usage",
+			Description:              "Show the tokens used by the server and their estimated cost",
+			DefaultMemberPermissions: &defaultMemberPermissions,
+		},
+		{
+			Name:        "prompt",
+			Description: "Set the prompt for the bot",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "set",
+					Description: "Set a custom prompt for the bot",
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "custom",
+							Description: "Set a custom prompt for the bot",
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Name:        "prompt",
+									Description: "The custom prompt for the bot",
+									Type:        discordgo.ApplicationCommandOptionString,
+									Required:    true,
+									MaxLength:   1000,
+								},
+							},
+						},
+						{
+							Name:        "default",
+							Description: "Put back the default prompt",
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "config",
+			Description: "Show or change the bot configuration",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Description: "List every setting and its value",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "channel",
+							Description: "The channel or category overriding the guild setting",
+							Type:        discordgo.ApplicationCommandOptionChannel,
+							ChannelTypes: []discordgo.ChannelType{
+								discordgo.ChannelTypeGuildText,
+								discordgo.ChannelTypeGuildCategory,
+								discordgo.ChannelTypeGuildForum,
+							},
+						},
+					},
+				},
+				{
+					Name:        "get",
+					Description: "Show a setting",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "The setting name",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:        "channel",
+							Description: "The channel or category overriding the guild setting",
+							Type:        discordgo.ApplicationCommandOptionChannel,
+							ChannelTypes: []discordgo.ChannelType{
+								discordgo.ChannelTypeGuildText,
+								discordgo.ChannelTypeGuildCategory,
+								discordgo.ChannelTypeGuildForum,
+							},
+						},
+					},
+				},
+				{
+					Name:        "set",
+					Description: "Change a setting",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "The setting name",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:        "value",
+							Description: "The new value",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+							MaxLength:   1000,
+						},
+						{
+							Name:        "channel",
+							Description: "The channel or category overriding the guild setting",
+							Type:        discordgo.ApplicationCommandOptionChannel,
+							ChannelTypes: []discordgo.ChannelType{
+								discordgo.ChannelTypeGuildText,
+								discordgo.ChannelTypeGuildCategory,
+								discordgo.ChannelTypeGuildForum,
+							},
+						},
+					},
+				},
+				{
+					Name:        "reset",
+					Description: "Put a setting back to its default",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "The setting name",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Name:        "channel",
+							Description: "The channel or category overriding the guild setting",
+							Type:        discordgo.ApplicationCommandOptionChannel,
+							ChannelTypes: []discordgo.ChannelType{
+								discordgo.ChannelTypeGuildText,
+								discordgo.ChannelTypeGuildCategory,
+								discordgo.ChannelTypeGuildForum,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+)
+
+func (b *Bot) commandHandlers() map[string]func(s Session, i *discordgo.InteractionCreate) {
+	return map[string]func(s Session, i *discordgo.InteractionCreate){
+		"config":        b.handleConfig,
+		"ping":          b.handlePing,
+		"temperature":   b.handleTemperature,
+		"threshold":     b.handleThreshold,
+		"toggle":        b.handleToggle,
+		"streaming":     b.handleStreaming,
+		"clean":         b.handleClean,
+		"messagescount": b.handleMessagesCount,
+		"maxtokens":     b.handleMaxTokens,
+		"provider":      b.handleProvider,
+		"model":         b.handleModel,
+		"prompt":        b.handlePrompt,
+		"trigger":       b.handleTrigger,
+		"usage":         b.handleUsage,
+	}
+}
+
+func (b *Bot) autocompleteHandlers() map[string]func(s Session, i *discordgo.InteractionCreate) {
+	return map[string]func(s Session, i *discordgo.InteractionCreate){
+		"config": b.autocompleteConfig,
+		"model":  b.autocompleteModel,
+	}
+}
+
+func (b *Bot) handlePing(s Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Pong!",
+		},
+	})
+}
+
+func (b *Bot) handleTemperature(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.SetFloat(context.Background(), settings.Guild(i.GuildID), "temperature", i.ApplicationCommandData().Options[0].FloatValue())
+	content := settingReply(err, fmt.Sprintf("Temperature set to %v", value), "Error setting temperature")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleThreshold(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.SetFloat(context.Background(), settings.Guild(i.GuildID), "threshold", i.ApplicationCommandData().Options[0].FloatValue())
+	content := settingReply(err, fmt.Sprintf("Threshold set to %v", value), "Error setting threshold")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleToggle(s Session, i *discordgo.InteractionCreate) {
+	current := b.settings.Get(context.Background(), settings.Guild(i.GuildID), "state").Bool()
+	value, err := b.settings.SetBool(context.Background(), settings.Guild(i.GuildID), "state", !current)
+	content := settingReply(err, fmt.Sprintf("Bot is now %v", value), "Error toggling bot")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleStreaming(s Session, i *discordgo.InteractionCreate) {
+	current := b.settings.Get(context.Background(), settings.Guild(i.GuildID), "streaming").Bool()
+	value, err := b.settings.SetBool(context.Background(), settings.Guild(i.GuildID), "streaming", !current)
+	content := settingReply(err, fmt.Sprintf("Streaming is now %v", value), "Error toggling streaming")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleClean(s Session, i *discordgo.InteractionCreate) {
+	messages, err := s.ChannelMessages(i.ChannelID, 100, "", "", "")
+	if err != nil {
+		fmt.Println("error getting messages,", err)
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Cleaning messages...",
+		},
+	})
+	messagesToDelete := make([]string, 0)
+	for idx := range messages {
+		if messages[idx].Author.ID == s.BotUserID() {
+			messagesToDelete = append(messagesToDelete, messages[idx].ID)
+		}
+	}
+	s.ChannelMessagesBulkDelete(i.ChannelID, messagesToDelete)
+	str := fmt.Sprintf("Messages cleaned")
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &str,
+	})
+	time.AfterFunc(10*time.Second, func() {
+		s.InteractionResponseDelete(i.Interaction)
+	})
+}
+
+func (b *Bot) handleMessagesCount(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.SetInt(context.Background(), settings.Guild(i.GuildID), "messagescount", i.ApplicationCommandData().Options[0].IntValue())
+	content := settingReply(err, fmt.Sprintf("Messages count set to %v", value), "Error setting messages count")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleMaxTokens(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.SetInt(context.Background(), settings.Guild(i.GuildID), "maxtokens", i.ApplicationCommandData().Options[0].IntValue())
+	content := settingReply(err, fmt.Sprintf("Max tokens set to %v", value), "Error setting max tokens")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleProvider(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.Set(context.Background(), settings.Guild(i.GuildID), "provider", i.ApplicationCommandData().Options[0].StringValue())
+	content := settingReply(err, fmt.Sprintf("Provider set to %v", value), "Error setting provider")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handleModel(s Session, i *discordgo.InteractionCreate) {
+	value, err := b.settings.Set(context.Background(), settings.Guild(i.GuildID), "model", i.ApplicationCommandData().Options[0].StringValue())
+	content := settingReply(err, fmt.Sprintf("Model set to %v", value), "Error setting model")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) handlePrompt(s Session, i *discordgo.InteractionCreate) {
+	options := i.ApplicationCommandData().Options
+	if options[0].Name != "set" {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Wrong option!",
+			},
+		})
+		return
+	}
+
+	options = options[0].Options
+	if options[0].Name == "default" {
+		err := b.settings.Reset(context.Background(), settings.Guild(i.GuildID), "prompt")
+		content := settingReply(err, "Prompt set to default", "Error setting prompt")
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+		return
+	}
+
+	if options[0].Name != "custom" {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Wrong option!",
+			},
+		})
+		return
+	}
+	_, err := b.settings.Set(context.Background(), settings.Guild(i.GuildID), "prompt", options[0].Options[0].StringValue())
+	content := settingReply(err, "Prompt correctly set", "Error setting prompt")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func (b *Bot) autocompleteModel(s Session, i *discordgo.InteractionCreate) {
+	query := i.ApplicationCommandData().Options[0].StringValue()
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, m := range provider.SearchModels(b.scopeProvider(settings.Guild(i.GuildID)).Name(), query) {
+		if len(choices) == 25 {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  m.Name,
+			Value: m.Name,
+		})
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
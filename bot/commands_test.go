@@ -0,0 +1,312 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/store"
+)
+
+func command(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			GuildID:   testGuild,
+			ChannelID: testChannel,
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name:    name,
+				Options: options,
+			},
+		},
+	}
+}
+
+func autocomplete(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	i := command(name, options...)
+	i.Type = discordgo.InteractionApplicationCommandAutocomplete
+	return i
+}
+
+func option(name string, kind discordgo.ApplicationCommandOptionType, value interface{}) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: kind, Value: value}
+}
+
+func numberOption(name string, value float64) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionNumber, value)
+}
+
+func intOption(name string, value int) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionInteger, float64(value))
+}
+
+func stringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionString, value)
+}
+
+func subcommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:    name,
+		Type:    discordgo.ApplicationCommandOptionSubCommand,
+		Options: options,
+	}
+}
+
+func TestCommandsHaveHandlers(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	handlers := b.commandHandlers()
+	for _, cmd := range commands {
+		if _, ok := handlers[cmd.Name]; !ok {
+			t.Errorf("command %v has no handler", cmd.Name)
+		}
+	}
+}
+
+func TestSettingCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *discordgo.InteractionCreate
+		want    string
+		setting string
+		value   string
+	}{
+		{"ping", command("ping"), "Pong!", "", ""},
+		{"temperature", command("temperature", numberOption("temperature", 0.7)), "Temperature set to 0.7", "temperature", "0.7"},
+		{"temperature out of range", command("temperature", numberOption("temperature", 2)), "Invalid value", "temperature", "0.5"},
+		{"threshold", command("threshold", numberOption("threshold", 0.3)), "Threshold set to 0.3", "threshold", "0.3"},
+		{"toggle", command("toggle"), "Bot is now on", "state", "on"},
+		{"streaming", command("streaming"), "Streaming is now on", "streaming", "on"},
+		{"messagescount", command("messagescount", intOption("messagescount", 50)), "Messages count set to 50", "messagescount", "50"},
+		{"messagescount out of range", command("messagescount", intOption("messagescount", 500)), "Invalid value", "messagescount", "100"},
+		{"maxtokens", command("maxtokens", intOption("maxtokens", 1000)), "Max tokens set to 1000", "maxtokens", "1000"},
+		{"maxtokens above model limit", command("maxtokens", intOption("maxtokens", 9000)), "Invalid value", "maxtokens", "100"},
+		{"provider", command("provider", stringOption("provider", "groq")), "Provider set to groq", "provider", "groq"},
+		{"provider not configured", command("provider", stringOption("provider", "openai")), "Invalid value", "provider", "groq"},
+		{"model", command("model", stringOption("model", "llama3-70b-8192")), "Model set to llama3-70b-8192", "model", "llama3-70b-8192"},
+		{"unknown model", command("model", stringOption("model", "gpt-2")), "Invalid value", "model", ""},
+		{"prompt", command("prompt", subcommand("set", subcommand("custom", stringOption("prompt", "Be nice")))), "Prompt correctly set", "prompt", "Be nice"},
+		{"default prompt", command("prompt", subcommand("set", subcommand("default"))), "Prompt set to default", "prompt", defaultInstructions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot(t, &fakeProvider{})
+			s := newFakeSession()
+
+			b.interactionCreate(s, tt.cmd)
+
+			resp := s.lastResponse()
+			if resp == nil {
+				t.Fatal("no response")
+			}
+			if !strings.HasPrefix(resp.Content, tt.want) {
+				t.Errorf("responded %q, want %q", resp.Content, tt.want)
+			}
+			if tt.setting == "" {
+				return
+			}
+			if got := b.settings.Get(context.Background(), settings.Guild(testGuild), tt.setting).Raw; got != tt.value {
+				t.Errorf("%v is %q, want %q", tt.setting, got, tt.value)
+			}
+		})
+	}
+}
+
+func TestToggleTwice(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+
+	b.interactionCreate(s, command("toggle"))
+	b.interactionCreate(s, command("toggle"))
+
+	if resp := s.lastResponse(); resp.Content != "Bot is now off" {
+		t.Errorf("responded %q", resp.Content)
+	}
+}
+
+func TestCleanCommand(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+	mine := s.post(testChannel, testBotID, "answer")
+	s.post(testChannel, "user", "question")
+
+	b.interactionCreate(s, command("clean"))
+
+	if len(s.bulkDeleted) != 1 || s.bulkDeleted[0] != mine.ID {
+		t.Errorf("deleted %v, want only %v", s.bulkDeleted, mine.ID)
+	}
+	if resp := s.lastResponse(); resp.Content != "Cleaning messages..." {
+		t.Errorf("responded %q", resp.Content)
+	}
+	if len(s.responseEdits) != 1 || *s.responseEdits[0].Content != "Messages cleaned" {
+		t.Errorf("the response was not edited once cleaned")
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+
+	b.interactionCreate(s, command("config", subcommand("set", stringOption("name", "threshold"), stringOption("value", "0.4"))))
+	embed := s.lastResponse().Embeds[0]
+	if embed.Title != "threshold" || embed.Fields[0].Value != "0.4" || embed.Fields[1].Value != "guild" {
+		t.Errorf("set showed %+v", embed.Fields)
+	}
+
+	b.interactionCreate(s, command("config", subcommand("get", stringOption("name", "threshold"))))
+	if embed := s.lastResponse().Embeds[0]; embed.Fields[0].Value != "0.4" {
+		t.Errorf("get showed %+v", embed.Fields)
+	}
+
+	b.interactionCreate(s, command("config", subcommand("reset", stringOption("name", "threshold"))))
+	if embed := s.lastResponse().Embeds[0]; embed.Fields[0].Value != "0.1" || embed.Fields[1].Value != "default" {
+		t.Errorf("reset showed %+v", embed.Fields)
+	}
+
+	b.interactionCreate(s, command("config", subcommand("list")))
+	if embed := s.lastResponse().Embeds[0]; len(embed.Fields) != len(b.settings.Registry.All()) {
+		t.Errorf("list showed %v settings", len(embed.Fields))
+	}
+}
+
+func TestConfigCommandChannel(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+	channel := option("channel", discordgo.ApplicationCommandOptionChannel, "random")
+
+	b.interactionCreate(s, command("config", subcommand("set", stringOption("name", "threshold"), stringOption("value", "0.9"), channel)))
+
+	value := b.settings.Get(context.Background(), settings.Channel(testGuild, "random"), "threshold")
+	if value.Raw != "0.9" || value.Source != "random" {
+		t.Errorf("channel threshold is %+v", value)
+	}
+	if value := b.settings.Get(context.Background(), settings.Guild(testGuild), "threshold"); !value.IsDefault() {
+		t.Errorf("guild threshold changed to %v", value.Raw)
+	}
+}
+
+func TestConfigCommandErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    *discordgo.InteractionCreate
+		member *discordgo.Member
+		want   string
+	}{
+		{"unknown setting", command("config", subcommand("get", stringOption("name", "colour"))), nil, "Unknown setting colour"},
+		{"invalid value", command("config", subcommand("set", stringOption("name", "threshold"), stringOption("value", "high"))), nil, "Invalid value"},
+		{"permission", command("config", subcommand("set", stringOption("name", "temperature"), stringOption("value", "0.2"))), &discordgo.Member{}, "You are not allowed to change temperature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot(t, &fakeProvider{})
+			s := newFakeSession()
+			tt.cmd.Member = tt.member
+
+			b.interactionCreate(s, tt.cmd)
+
+			if resp := s.lastResponse(); !strings.HasPrefix(resp.Content, tt.want) {
+				t.Errorf("responded %q, want %q", resp.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *discordgo.InteractionCreate
+		want string
+	}{
+		{"config", autocomplete("config", subcommand("get", &discordgo.ApplicationCommandInteractionDataOption{
+			Name:    "name",
+			Type:    discordgo.ApplicationCommandOptionString,
+			Value:   "temp",
+			Focused: true,
+		})), "temperature"},
+		{"model", autocomplete("model", stringOption("model", "llama3-70b")), "llama3-70b-8192"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot(t, &fakeProvider{})
+			s := newFakeSession()
+
+			b.interactionCreate(s, tt.cmd)
+
+			if len(s.responses) != 1 || s.responses[0].Type != discordgo.InteractionApplicationCommandAutocompleteResult {
+				t.Fatalf("responded %+v", s.responses)
+			}
+			choices := s.responses[0].Data.Choices
+			if len(choices) != 1 || choices[0].Value != tt.want {
+				t.Errorf("suggested %+v, want %v", choices, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageCommand(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+
+	b.interactionCreate(s, command("usage"))
+	if embeds := s.lastResponse().Embeds; len(embeds) != 1 || len(embeds[0].Fields) != 3 {
+		t.Fatalf("got %+v, want today, this month and quota only", s.lastResponse())
+	}
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		b.usage.Record(context.Background(), store.Usage{
+			GuildID:          testGuild,
+			ChannelID:        testChannel,
+			UserID:           user,
+			Provider:         "groq",
+			Model:            "llama-3.1-8b-instant",
+			PromptTokens:     100,
+			CompletionTokens: 20,
+		})
+	}
+	b.interactionCreate(s, command("usage"))
+	fields := s.lastResponse().Embeds[0].Fields
+	if len(fields) != 5 || !strings.HasPrefix(fields[0].Value, "360 tokens in 3 requests") {
+		t.Fatalf("got fields %+v", fields)
+	}
+	if !strings.HasPrefix(fields[4].Value, "<@alice>: 240 tokens") {
+		t.Errorf("top users are %q", fields[4].Value)
+	}
+}
+
+func TestTriggerCommand(t *testing.T) {
+	b := newTestBot(t, &fakeProvider{})
+	s := newFakeSession()
+
+	b.interactionCreate(s, command("trigger", subcommand("add",
+		stringOption("kind", "keyword"),
+		stringOption("pattern", "pizza"),
+		numberOption("probability", 0.25),
+	)))
+	if got := s.lastResponse().Content; got != "Added trigger #1: keyword `pizza` (25%)" {
+		t.Errorf("add answered %q", got)
+	}
+
+	b.interactionCreate(s, command("trigger", subcommand("add", stringOption("kind", "regex"), stringOption("pattern", "("))))
+	if got := s.lastResponse().Content; !strings.HasPrefix(got, "Invalid trigger") {
+		t.Errorf("add answered %q to an invalid regex", got)
+	}
+
+	b.interactionCreate(s, command("trigger", subcommand("list")))
+	if embeds := s.lastResponse().Embeds; len(embeds) != 1 || !strings.Contains(embeds[0].Description, "pizza") {
+		t.Errorf("list answered %+v", s.lastResponse())
+	}
+
+	b.interactionCreate(s, command("trigger", subcommand("remove", intOption("id", 2))))
+	if got := s.lastResponse().Content; got != "There is no trigger #2" {
+		t.Errorf("remove answered %q", got)
+	}
+	b.interactionCreate(s, command("trigger", subcommand("remove", intOption("id", 1))))
+	if got := s.lastResponse().Content; got != "Removed trigger #1" {
+		t.Errorf("remove answered %q", got)
+	}
+}
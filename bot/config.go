@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/settings"
+)
+
+// maxEmbedFieldLength is the longest value Discord accepts in an embed field.
+const maxEmbedFieldLength = 1024
+
+func (b *Bot) handleConfig(s Session, i *discordgo.InteractionCreate) {
+	ctx := context.Background()
+	sub := i.ApplicationCommandData().Options[0]
+	options := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, o := range sub.Options {
+		options[o.Name] = o
+	}
+
+	scope := settings.Guild(i.GuildID)
+	title := "Configuration"
+	if channel, ok := options["channel"]; ok {
+		scope = channelScope(s, i.GuildID, channel.ChannelValue(nil).ID)
+		title = fmt.Sprintf("Configuration of <#%v>", channel.ChannelValue(nil).ID)
+	}
+
+	if sub.Name == "list" {
+		embed := &discordgo.MessageEmbed{Title: title}
+		for _, setting := range b.settings.Registry.All() {
+			value := b.settings.Get(ctx, scope, setting.Name)
+			text := formatSettingValue(value.Raw) + " (" + formatSettingSource(value) + ")"
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  setting.Name,
+				Value: truncate(text, maxEmbedFieldLength),
+			})
+		}
+		respondEmbed(s, i, embed)
+		return
+	}
+
+	name := options["name"].StringValue()
+	setting, ok := b.settings.Registry.Lookup(name)
+	if !ok {
+		respondContent(s, i, fmt.Sprintf("Unknown setting %v", name))
+		return
+	}
+
+	if sub.Name != "get" && i.Member != nil && i.Member.Permissions&setting.Permission != setting.Permission {
+		respondContent(s, i, fmt.Sprintf("You are not allowed to change %v", name))
+		return
+	}
+
+	switch sub.Name {
+	case "get":
+		respondEmbed(s, i, settingEmbed(setting, b.settings.Get(ctx, scope, name)))
+	case "set":
+		_, err := b.settings.Set(ctx, scope, name, options["value"].StringValue())
+		if err != nil {
+			respondContent(s, i, settingReply(err, "", fmt.Sprintf("Error setting %v", name)))
+			return
+		}
+		respondEmbed(s, i, settingEmbed(setting, b.settings.Get(ctx, scope, name)))
+	case "reset":
+		err := b.settings.Reset(ctx, scope, name)
+		if err != nil {
+			respondContent(s, i, settingReply(err, "", fmt.Sprintf("Error resetting %v", name)))
+			return
+		}
+		respondEmbed(s, i, settingEmbed(setting, b.settings.Get(ctx, scope, name)))
+	default:
+		respondContent(s, i, "Wrong option!")
+	}
+}
+
+func (b *Bot) autocompleteConfig(s Session, i *discordgo.InteractionCreate) {
+	query := ""
+	for _, o := range i.ApplicationCommandData().Options[0].Options {
+		if o.Focused {
+			query = strings.ToLower(o.StringValue())
+		}
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, setting := range b.settings.Registry.All() {
+		if len(choices) == 25 {
+			break
+		}
+		if strings.Contains(setting.Name, query) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  setting.Name,
+				Value: setting.Name,
+			})
+		}
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+func settingEmbed(setting *settings.Setting, value settings.Value) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       setting.Name,
+		Description: setting.Description,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:  "Current value",
+				Value: truncate(formatSettingValue(value.Raw), maxEmbedFieldLength),
+			},
+			{
+				Name:   "Set for",
+				Value:  formatSettingSource(value),
+				Inline: true,
+			},
+			{
+				Name:  "Default",
+				Value: truncate(formatSettingValue(setting.Default), maxEmbedFieldLength),
+			},
+			{
+				Name:   "Allowed values",
+				Value:  setting.Range(),
+				Inline: true,
+			},
+		},
+	}
+}
+
+func formatSettingValue(value string) string {
+	if value == "" {
+		return "*empty*"
+	}
+	return value
+}
+
+func formatSettingSource(value settings.Value) string {
+	switch value.Source {
+	case "":
+		return "default"
+	case settings.GuildSource:
+		return "guild"
+	default:
+		return fmt.Sprintf("<#%v>", value.Source)
+	}
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+func respondContent(s Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+func respondEmbed(s Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+}
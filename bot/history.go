@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+)
+
+// conversation is the history sent to the model, oldest first. The messages
+// of the reply chain are pinned: they are kept in preference to the others
+// when the conversation does not fit in a context window.
+type conversation struct {
+	messages []provider.Message
+	// pinned holds the indexes in messages of the reply chain.
+	pinned map[int]bool
+}
+
+// buildConversation turns channel messages and the reply chain of the
+// message answered, both newest first as returned by Discord, into a
+// chronological conversation. The bot's own messages become assistant turns
+// so the model does not mistake them for someone else talking.
+func buildConversation(botID string, messages, chain []*discordgo.Message) conversation {
+	inChain := make(map[string]bool, len(chain))
+	for _, msg := range chain {
+		inChain[msg.ID] = true
+	}
+
+	c := conversation{pinned: map[int]bool{}}
+	messages = withReplyChain(messages, chain)
+	for idx := len(messages) - 1; idx >= 0; idx-- {
+		msg := messages[idx]
+		if msg.Author == nil || msg.Content == "" {
+			continue
+		}
+		message := provider.Message{
+			Role:    provider.RoleUser,
+			Content: authorName(msg.Author) + ": " + msg.Content,
+		}
+		if msg.Author.ID == botID {
+			message = provider.Message{
+				Role:    provider.RoleAssistant,
+				Content: msg.Content,
+			}
+		}
+		if inChain[msg.ID] {
+			c.pinned[len(c.messages)] = true
+		}
+		c.messages = append(c.messages, message)
+	}
+	return c
+}
+
+// fit keeps the messages of the conversation that fit in budget tokens, and
+// reports how many were dropped. The reply chain gets up to half of the
+// budget and the other messages what is left, the most recent ones first in
+// both cases.
+func (c conversation) fit(budget int) ([]provider.Message, int) {
+	var chain, others []provider.Message
+	for idx, msg := range c.messages {
+		if c.pinned[idx] {
+			chain = append(chain, msg)
+		} else {
+			others = append(others, msg)
+		}
+	}
+	chain, droppedChain := fitHistory(chain, budget/2)
+	_, droppedOthers := fitHistory(others, budget-historyTokens(chain))
+
+	kept := make([]provider.Message, 0, len(c.messages)-droppedChain-droppedOthers)
+	for idx, msg := range c.messages {
+		if c.pinned[idx] && droppedChain > 0 {
+			droppedChain--
+			continue
+		}
+		if !c.pinned[idx] && droppedOthers > 0 {
+			droppedOthers--
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	return kept, len(c.messages) - len(kept)
+}
+
+// maxReplyChain bounds how many replied-to messages are followed up.
+const maxReplyChain = 5
+
+// referencedMessage returns the message msg replies to, or nil when it is not
+// a reply. When Discord did not include it in the event, it is fetched and
+// kept in msg for the next call.
+func referencedMessage(s Session, msg *discordgo.Message) *discordgo.Message {
+	if msg.ReferencedMessage != nil {
+		return msg.ReferencedMessage
+	}
+	ref := msg.MessageReference
+	if msg.Type != discordgo.MessageTypeReply || ref == nil || ref.MessageID == "" {
+		return nil
+	}
+
+	channelID := ref.ChannelID
+	if channelID == "" {
+		channelID = msg.ChannelID
+	}
+	referenced, err := s.ChannelMessage(channelID, ref.MessageID)
+	if err != nil {
+		// Most likely deleted since.
+		log.Println("error getting replied-to message,", err)
+		return nil
+	}
+	msg.ReferencedMessage = referenced
+	return referenced
+}
+
+// repliedTo returns the messages m replies to, newest first: the message it
+// replies to, the one that message replies to, and so on up to
+// maxReplyChain.
+func repliedTo(s Session, m *discordgo.Message) []*discordgo.Message {
+	var chain []*discordgo.Message
+	for msg := referencedMessage(s, m); msg != nil; msg = referencedMessage(s, msg) {
+		chain = append(chain, msg)
+		if len(chain) == maxReplyChain {
+			break
+		}
+	}
+	return chain
+}
+
+// withReplyChain adds to messages, newest first, the messages of chain too
+// old to be among them, so the conversation being replied to is part of the
+// history.
+func withReplyChain(messages, chain []*discordgo.Message) []*discordgo.Message {
+	seen := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		seen[msg.ID] = true
+	}
+	for _, msg := range chain {
+		if !seen[msg.ID] {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func authorName(u *discordgo.User) string {
+	if u.GlobalName != "" {
+		return u.GlobalName
+	}
+	return u.Username
+}
+
+// fitHistory keeps the most recent messages of history whose estimated size
+// fits in budget tokens, and reports how many older messages were dropped.
+func fitHistory(history []provider.Message, budget int) ([]provider.Message, int) {
+	used := 0
+	start := len(history)
+	for start > 0 {
+		cost := provider.EstimateMessageTokens(history[start-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	return history[start:], start
+}
+
+func historyTokens(history []provider.Message) int {
+	tokens := 0
+	for _, msg := range history {
+		tokens += provider.EstimateMessageTokens(msg)
+	}
+	return tokens
+}
+
+// historyBudget is what is left of the model's context window once the
+// instructions and the reserved output are accounted for.
+func historyBudget(model provider.Model, params *GroqParams) int {
+	instructions := provider.EstimateMessageTokens(provider.Message{
+		Role:    provider.RoleSystem,
+		Content: params.Instructions,
+	})
+	return model.ContextWindow - instructions - params.MaxTokens
+}
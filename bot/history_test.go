@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"polynux/disgoroq/provider"
+)
+
+// sizedMessages returns count user messages, oldest first, each costing
+// tokens as estimated.
+func sizedMessages(count, tokens int) []provider.Message {
+	messages := make([]provider.Message, count)
+	for idx := range messages {
+		content := fmt.Sprintf("%03d", idx)
+		content += strings.Repeat("x", (tokens-4)*3-len(content))
+		messages[idx] = provider.Message{Role: provider.RoleUser, Content: content}
+	}
+	return messages
+}
+
+func TestFitHistory(t *testing.T) {
+	history := sizedMessages(5, 10)
+	if cost := provider.EstimateMessageTokens(history[0]); cost != 10 {
+		t.Fatalf("messages cost %v tokens, want 10", cost)
+	}
+
+	tests := []struct {
+		budget, dropped int
+	}{
+		{50, 0},
+		{100, 0},
+		{49, 1},
+		{30, 2},
+		{10, 4},
+		{9, 5},
+		{0, 5},
+		{-20, 5},
+	}
+	for _, tt := range tests {
+		kept, dropped := fitHistory(history, tt.budget)
+		if dropped != tt.dropped {
+			t.Errorf("budget %v: dropped %v messages, want %v", tt.budget, dropped, tt.dropped)
+		}
+		// The oldest messages go first.
+		if !reflect.DeepEqual(kept, history[tt.dropped:]) {
+			t.Errorf("budget %v: kept %v messages, not the newest", tt.budget, len(kept))
+		}
+	}
+}
+
+func TestHistoryBudget(t *testing.T) {
+	params := &GroqParams{Instructions: strings.Repeat("x", 30), MaxTokens: 100}
+	// 30 characters are 10 tokens, plus 4 for the message.
+	model := provider.Model{ContextWindow: 1000}
+	if got := historyBudget(model, params); got != 1000-14-100 {
+		t.Errorf("got %v, want %v", got, 1000-14-100)
+	}
+
+	// Instructions and output larger than the window leave no room at all.
+	model.ContextWindow = 110
+	if got := historyBudget(model, params); got > 0 {
+		t.Errorf("got %v, want no budget", got)
+	}
+	if kept, dropped := fitHistory(sizedMessages(3, 10), historyBudget(model, params)); len(kept) != 0 || dropped != 3 {
+		t.Errorf("kept %v messages with no budget", len(kept))
+	}
+}
+
+func TestConversationFitKeepsReplyChain(t *testing.T) {
+	messages := sizedMessages(6, 10)
+	c := conversation{messages: messages, pinned: map[int]bool{0: true, 3: true}}
+
+	tests := []struct {
+		budget  int
+		want    []provider.Message
+		dropped int
+	}{
+		{60, messages, 0},
+		// The chain fits in half of the budget, the newest others in the rest.
+		{40, []provider.Message{messages[0], messages[3], messages[4], messages[5]}, 2},
+		// Only the newest message of the chain fits in half of the budget.
+		{30, []provider.Message{messages[3], messages[4], messages[5]}, 3},
+		{0, []provider.Message{}, 6},
+	}
+	for _, tt := range tests {
+		kept, dropped := c.fit(tt.budget)
+		if !reflect.DeepEqual(kept, tt.want) || dropped != tt.dropped {
+			t.Errorf("budget %v: kept %v messages and dropped %v", tt.budget, len(kept), dropped)
+		}
+	}
+
+	// Without a chain, the conversation is fitted like any history.
+	c = conversation{messages: messages}
+	kept, dropped := c.fit(30)
+	if want, wantDropped := fitHistory(messages, 30); !reflect.DeepEqual(kept, want) || dropped != wantDropped {
+		t.Errorf("kept %v messages and dropped %v", len(kept), dropped)
+	}
+}
+
+func TestAskKeepsReplyChainInSmallContext(t *testing.T) {
+	p := &fakeProvider{content: "yes"}
+	b := newTestBot(t, p)
+	s := newFakeSession()
+
+	question := s.post(testChannel, "alice", "what is the capital of France?")
+	answer := s.reply(question.Message, testBotID, "Paris, obviously")
+	for i := 0; i < 10; i++ {
+		s.post(testChannel, "bob", strings.Repeat("chatter ", 10))
+	}
+	m := s.reply(answer.Message, "alice", "are you sure?")
+	messages, err := getMessages(s, testChannel, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := buildConversation(testBotID, messages, repliedTo(s, m.Message))
+
+	// Room for the chain, the message answered and one more.
+	model := provider.Model{Provider: "groq", Name: "small", ContextWindow: 120}
+	params := GroqParams{MaxTokens: 20, Instructions: "Be brief"}
+	if _, err := b.ask(context.Background(), m.Message, []candidate{{p, model}}, params, history, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, msg := range p.lastRequest().Messages[1:] {
+		got = append(got, msg.Content)
+	}
+	want := []string{
+		"alice: what is the capital of France?",
+		"Paris, obviously",
+		"bob: " + strings.Repeat("chatter ", 10),
+		"alice: are you sure?",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("history is %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/ratelimit"
+	"polynux/disgoroq/settings"
+)
+
+// noticeLimit spaces the notices telling a user to wait, so they are not
+// spam themselves.
+var noticeLimit = ratelimit.Limit{Burst: 1, Refill: time.Minute}
+
+// allowReply applies the user, channel and guild rate limits to a message.
+// A mention only counts against its author's limit, who is told how long to
+// wait when over it.
+func (b *Bot) allowReply(ctx context.Context, s Session, scope settings.Scope, m *discordgo.MessageCreate, mentioned bool) bool {
+	ok, wait := b.limiter.Allow(b.replyRequests(ctx, scope, m, mentioned)...)
+	if ok || !mentioned {
+		return ok
+	}
+
+	b.notice(s, m, "ratelimit", fmt.Sprintf("Please wait %v before asking me again.", wait.Round(time.Second)+time.Second))
+	return false
+}
+
+// replyRequests are the rate limits answering m counts against.
+func (b *Bot) replyRequests(ctx context.Context, scope settings.Scope, m *discordgo.MessageCreate, mentioned bool) []ratelimit.Request {
+	requests := []ratelimit.Request{
+		{Key: "user/" + m.GuildID + "/" + m.Author.ID, Limit: b.scopeLimit(ctx, scope, "ratelimit_user")},
+	}
+	if !mentioned {
+		requests = append(requests,
+			ratelimit.Request{Key: "channel/" + m.ChannelID, Limit: b.scopeLimit(ctx, scope, "ratelimit_channel")},
+			ratelimit.Request{Key: "guild/" + m.GuildID, Limit: b.scopeLimit(ctx, scope, "ratelimit_guild")},
+		)
+	}
+	return requests
+}
+
+// notice replies content to m, unless the author was given a notice of the
+// same kind within noticeLimit.
+func (b *Bot) notice(s Session, m *discordgo.MessageCreate, kind, content string) {
+	notice := ratelimit.Request{Key: "notice/" + kind + "/" + m.GuildID + "/" + m.Author.ID, Limit: noticeLimit}
+	if ok, _ := b.limiter.Allow(notice); !ok {
+		return
+	}
+	s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:   content,
+		Reference: m.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{},
+		},
+	})
+}
+
+func (b *Bot) scopeLimit(ctx context.Context, scope settings.Scope, name string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(b.settings.Get(ctx, scope, name).String())
+	if err != nil {
+		log.Printf("guild %v: ignoring %v, %v", scope.GuildID, name, err)
+	}
+	return limit
+}
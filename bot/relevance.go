@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/settings"
+)
+
+const (
+	// relevanceMessages is the number of recent messages the classifier
+	// reads.
+	relevanceMessages = 10
+	// burstGap ends a burst of messages: a channel quiet for longer gets a
+	// new decision.
+	burstGap = 30 * time.Second
+	// burstMax bounds how long a decision lasts, however busy the channel.
+	burstMax = 2 * time.Minute
+	// neutralRelevance keeps the prior, for bursts the classifier failed to
+	// score.
+	neutralRelevance = 0.5
+)
+
+const relevancePrompt = `You decide whether a chat bot should join a Discord conversation. Messages written by the bot start with "bot:".
+Rate from 0 to 10 how much the last messages invite a reply from the bot: 0 when they do not concern it, 10 when someone talks to it or asks something it could answer.
+Answer with the number only.`
+
+var relevanceScore = regexp.MustCompile(`\d+`)
+
+// burst is the relevance decided for a channel during a burst of messages.
+type burst struct {
+	started time.Time
+	last    time.Time
+	// decided is closed once score is set, so the messages of the burst
+	// arriving while it is being scored wait for it.
+	decided chan struct{}
+	score   float64
+}
+
+func (b *burst) decide(score float64) {
+	b.score = score
+	close(b.decided)
+}
+
+// burstCache keeps one relevance decision per channel and burst of
+// messages, so the classifier is asked once per burst rather than once per
+// message.
+type burstCache struct {
+	mu     sync.Mutex
+	bursts map[string]*burst
+	now    func() time.Time
+}
+
+func newBurstCache() *burstCache {
+	return &burstCache{
+		bursts: map[string]*burst{},
+		now:    time.Now,
+	}
+}
+
+// join returns the burst going on in a channel, which the message asking for
+// it extends. When there is none, it starts one and reports true: the caller
+// then decides its relevance.
+func (c *burstCache) join(channelID string) (*burst, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if b, ok := c.bursts[channelID]; ok && !b.ended(now) {
+		b.last = now
+		return b, false
+	}
+
+	for id, b := range c.bursts {
+		if b.ended(now) {
+			delete(c.bursts, id)
+		}
+	}
+	b := &burst{started: now, last: now, decided: make(chan struct{})}
+	c.bursts[channelID] = b
+	return b, true
+}
+
+func (b *burst) ended(now time.Time) bool {
+	return now.Sub(b.last) > burstGap || now.Sub(b.started) > burstMax
+}
+
+// combineRelevance updates prior, the probability to answer without the
+// classifier, with its score as a likelihood: a score of 0.5 keeps the
+// prior, higher scores raise it and lower ones lower it. A prior of 0 or 1
+// is left alone.
+func combineRelevance(prior, score float64) float64 {
+	if prior <= 0 || prior >= 1 {
+		return prior
+	}
+	score = min(max(score, 0.01), 0.99)
+	odds := prior / (1 - prior) * score / (1 - score)
+	return odds / (1 + odds)
+}
+
+// relevance scores from 0 to 1 how much the conversation in the channel of m
+// invites a reply. The first message of a burst asks the classifier, and the
+// others wait for its answer. A classifier failing counts as neutral for the
+// rest of the burst, so it is not asked again for every message.
+func (b *Bot) relevance(ctx context.Context, s Session, scope settings.Scope, m *discordgo.MessageCreate) (float64, error) {
+	burst, started := b.bursts.join(m.ChannelID)
+	if !started {
+		select {
+		case <-burst.decided:
+			return burst.score, nil
+		case <-ctx.Done():
+			return neutralRelevance, ctx.Err()
+		}
+	}
+
+	score := neutralRelevance
+	defer func() { burst.decide(score) }()
+	classified, err := b.classify(ctx, s, scope, m)
+	if err != nil {
+		return score, err
+	}
+	score = classified
+	return score, nil
+}
+
+func (b *Bot) classify(ctx context.Context, s Session, scope settings.Scope, m *discordgo.MessageCreate) (float64, error) {
+	messages, err := getMessages(s, m.ChannelID, relevanceMessages)
+	if err != nil {
+		return 0, err
+	}
+
+	c := b.classifier(scope)
+	params := GroqParams{
+		Model:        c.model.Name,
+		MaxTokens:    4,
+		Instructions: relevancePrompt,
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: transcript(s.BotUserID(), messages)},
+		},
+	}
+	resp, err := b.complete(ctx, c.provider, &params, nil)
+	if err != nil {
+		return 0, err
+	}
+	b.recordUsage(m.Message, c, &params, resp)
+
+	return parseRelevance(resp.Content)
+}
+
+// classifier returns the provider and model scoring relevance for scope.
+func (b *Bot) classifier(scope settings.Scope) candidate {
+	p := b.scopeProvider(scope)
+	name := b.settings.Get(context.Background(), scope, "classifier_model").String()
+	if model, ok := provider.LookupModel(p.Name(), name); ok {
+		return candidate{p, model}
+	}
+	return candidate{p, defaultModel(p)}
+}
+
+// transcript writes messages, newest first, as a conversation the
+// classifier reads in order.
+func transcript(botID string, messages []*discordgo.Message) string {
+	lines := make([]string, 0, len(messages))
+	for idx := len(messages) - 1; idx >= 0; idx-- {
+		msg := messages[idx]
+		if msg.Author == nil || msg.Content == "" {
+			continue
+		}
+		name := authorName(msg.Author)
+		if msg.Author.ID == botID {
+			name = "bot"
+		}
+		lines = append(lines, name+": "+msg.Content)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseRelevance reads the classifier's 0 to 10 rating as a score from 0 to
+// 1.
+func parseRelevance(content string) (float64, error) {
+	n, err := strconv.Atoi(relevanceScore.FindString(content))
+	if err != nil {
+		return 0, fmt.Errorf("classifier answered %q instead of a rating", content)
+	}
+	return float64(min(n, 10)) / 10, nil
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/ratelimit"
+	"polynux/disgoroq/settings"
+)
+
+func TestCombineRelevance(t *testing.T) {
+	tests := []struct {
+		prior, score, want float64
+	}{
+		{0.1, 0.5, 0.1},
+		{0.1, 0.9, 0.5},
+		{0.5, 0.2, 0.2},
+		{0, 1, 0},
+		{1, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := combineRelevance(tt.prior, tt.score); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("combineRelevance(%v, %v) = %v, want %v", tt.prior, tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestParseRelevance(t *testing.T) {
+	tests := []struct {
+		content string
+		want    float64
+		err     bool
+	}{
+		{"7", 0.7, false},
+		{" 10.", 1, false},
+		{"Rating: 3/10", 0.3, false},
+		{"42", 1, false},
+		{"no idea", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRelevance(tt.content)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseRelevance(%q) = %v, %v", tt.content, got, err)
+		}
+	}
+}
+
+func TestBurstCache(t *testing.T) {
+	now := time.Now()
+	c := newBurstCache()
+	c.now = func() time.Time { return now }
+
+	first, started := c.join(testChannel)
+	if !started {
+		t.Fatal("joined a burst before any")
+	}
+	if b, started := c.join(testChannel); started || b != first {
+		t.Fatal("started a second burst while the first is being decided")
+	}
+	first.decide(0.8)
+
+	// A busy channel keeps its decision up to burstMax.
+	for elapsed := time.Duration(0); elapsed < burstMax; elapsed += burstGap / 2 {
+		if b, started := c.join(testChannel); started || b.score != 0.8 {
+			t.Fatalf("lost the decision after %v", elapsed)
+		}
+		now = now.Add(burstGap / 2)
+	}
+	now = now.Add(burstGap / 2)
+	b, started := c.join(testChannel)
+	if !started {
+		t.Error("kept the decision past burstMax")
+	}
+	b.decide(0.2)
+
+	now = now.Add(burstGap + time.Second)
+	if _, started := c.join(testChannel); !started {
+		t.Error("kept the decision after the channel went quiet")
+	}
+}
+
+// classifierRequests counts the requests p got from the relevance
+// classifier.
+func classifierRequests(p *fakeProvider) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, req := range p.requests {
+		if req.Messages[0].Role == provider.RoleSystem && req.Messages[0].Content == relevancePrompt {
+			count++
+		}
+	}
+	return count
+}
+
+func TestMessageCreateRelevance(t *testing.T) {
+	p := &fakeProvider{content: "8"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"activation": "relevance", "threshold": "0.5", "ratelimit_user": "off", "ratelimit_channel": "off", "ratelimit_guild": "off"})
+	s := newFakeSession()
+
+	for _, content := range []string{"anyone around?", "hello?", "helloooo?"} {
+		b.messageCreate(s, s.post(testChannel, "alice", content))
+	}
+	if count := classifierRequests(p); count != 1 {
+		t.Fatalf("asked the classifier %v times in a burst, want 1", count)
+	}
+	day, _, _ := b.usage.Totals(context.Background(), testGuild)
+	if day.Requests < 1 {
+		t.Error("the classifier's usage was not recorded")
+	}
+
+	b.messageCreate(s, s.post("other", "alice", "hi"))
+	if count := classifierRequests(p); count != 2 {
+		t.Errorf("asked the classifier %v times for two channels, want 2", count)
+	}
+
+	setSettings(t, b, map[string]string{"threshold": "0"})
+	b.messageCreate(s, s.post("third", "alice", "hi"))
+	if count := classifierRequests(p); count != 2 {
+		t.Error("asked the classifier with a threshold of 0")
+	}
+}
+
+func TestRelevanceOncePerBurst(t *testing.T) {
+	p := newGatedProvider("8")
+	b := newTestBot(t, p)
+	s := newFakeSession()
+	scope := settings.Guild(testGuild)
+
+	var wg sync.WaitGroup
+	scores := make(chan float64, 5)
+	score := func() {
+		defer wg.Done()
+		score, err := b.relevance(context.Background(), s, scope, s.post(testChannel, "alice", "hello?"))
+		if err != nil {
+			t.Error(err)
+		}
+		scores <- score
+	}
+
+	wg.Add(1)
+	go score()
+	<-p.started
+	// Messages arriving while the first one is scored wait for its score.
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go score()
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(scores) != 0 {
+		t.Fatal("a message got a score before the classifier answered")
+	}
+
+	close(p.release)
+	wg.Wait()
+	close(scores)
+	for score := range scores {
+		if score != 0.8 {
+			t.Errorf("got a score of %v, want 0.8", score)
+		}
+	}
+	if count := classifierRequests(&p.fakeProvider); count != 1 {
+		t.Errorf("asked the classifier %v times, want 1", count)
+	}
+}
+
+func TestRelevanceSkippedWhenRateLimited(t *testing.T) {
+	p := &fakeProvider{content: "8"}
+	b := newTestBot(t, p)
+	enable(t, b)
+	setSettings(t, b, map[string]string{"activation": "relevance", "threshold": "0.5", "ratelimit_guild": "1/1h"})
+	s := newFakeSession()
+
+	// The guild answered another message meanwhile.
+	b.limiter.Allow(ratelimit.Request{Key: "guild/" + testGuild, Limit: ratelimit.Limit{Burst: 1, Refill: time.Hour}})
+
+	b.messageCreate(s, s.post(testChannel, "alice", "anyone around?"))
+	if count := classifierRequests(p); count != 0 {
+		t.Errorf("asked the classifier %v times for a message the rate limit rejects", count)
+	}
+}
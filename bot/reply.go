@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// replyChain is a reply spread over as many messages as its content needs,
+// each replying to the previous one.
+type replyChain struct {
+	s         Session
+	channelID string
+	reference *discordgo.MessageReference
+	ids       []string
+	chunks    []string
+}
+
+func newReplyChain(s Session, channelID string, reference *discordgo.MessageReference) *replyChain {
+	return &replyChain{
+		s:         s,
+		channelID: channelID,
+		reference: reference,
+	}
+}
+
+// update makes the chain show content, editing the messages already sent,
+// sending new ones for the chunks that do not fit and deleting those left
+// over when content got shorter.
+func (r *replyChain) update(content string) error {
+	allowedMentions := &discordgo.MessageAllowedMentions{
+		Parse: []discordgo.AllowedMentionType{},
+	}
+
+	chunks := splitMessage(content, maxMessageLength)
+	for len(r.ids) > len(chunks) {
+		last := len(r.ids) - 1
+		if err := r.s.ChannelMessageDelete(r.channelID, r.ids[last]); err != nil {
+			return err
+		}
+		r.ids, r.chunks = r.ids[:last], r.chunks[:last]
+	}
+
+	for idx, chunk := range chunks {
+		if idx < len(r.ids) {
+			if r.chunks[idx] == chunk {
+				continue
+			}
+			_, err := r.s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+				ID:              r.ids[idx],
+				Channel:         r.channelID,
+				Content:         &chunk,
+				AllowedMentions: allowedMentions,
+			})
+			if err != nil {
+				return err
+			}
+			r.chunks[idx] = chunk
+			continue
+		}
+
+		reference := r.reference
+		if idx > 0 {
+			reference = &discordgo.MessageReference{
+				MessageID: r.ids[idx-1],
+				ChannelID: r.channelID,
+				GuildID:   r.reference.GuildID,
+			}
+		}
+		msg, err := r.s.ChannelMessageSendComplex(r.channelID, &discordgo.MessageSend{
+			Content:         chunk,
+			Reference:       reference,
+			AllowedMentions: allowedMentions,
+		})
+		if err != nil {
+			return err
+		}
+		r.ids = append(r.ids, msg.ID)
+		r.chunks = append(r.chunks, chunk)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of the Discord API the bot uses. Handlers only talk to
+// Discord through it, so they can be tested against a fake.
+type Session interface {
+	// BotUserID is the ID of the bot's own user.
+	BotUserID() string
+	// StateChannel looks a channel up in the state cache, without any request.
+	StateChannel(channelID string) (*discordgo.Channel, error)
+
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+}
+
+// discordSession is the Session of a live discordgo connection.
+type discordSession struct {
+	*discordgo.Session
+}
+
+func (s discordSession) BotUserID() string {
+	return s.State.User.ID
+}
+
+func (s discordSession) StateChannel(channelID string) (*discordgo.Channel, error) {
+	return s.State.Channel(channelID)
+}
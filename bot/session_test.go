@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testBotID   = "bot"
+	testGuild   = "guild"
+	testChannel = "channel"
+)
+
+// sentMessage is a message sent through a fakeSession.
+type sentMessage struct {
+	ChannelID string
+	*discordgo.MessageSend
+}
+
+// messagesRequest is a ChannelMessages call made on a fakeSession.
+type messagesRequest struct {
+	ChannelID string
+	Limit     int
+	BeforeID  string
+}
+
+// fakeSession is a Session answering from memory and recording everything
+// the bot sends, so handlers can be tested without Discord.
+type fakeSession struct {
+	mu sync.Mutex
+
+	channels map[string]*discordgo.Channel
+	// messages holds the history of each channel, newest first like the API
+	// returns it.
+	messages map[string][]*discordgo.Message
+	// sendErr makes every message send fail when set.
+	sendErr error
+	nextID  int
+
+	sent             []sentMessage
+	edits            []*discordgo.MessageEdit
+	messagesRequests []messagesRequest
+	messageRequests  []string
+	deleted          []string
+	bulkDeleted      []string
+	responses        []*discordgo.InteractionResponse
+	responseEdits    []*discordgo.WebhookEdit
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		channels: map[string]*discordgo.Channel{},
+		messages: map[string][]*discordgo.Message{},
+	}
+}
+
+// post adds a message to a channel and returns the event Discord would
+// send for it.
+func (s *fakeSession) post(channelID, authorID, content string, mentions ...string) *discordgo.MessageCreate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &discordgo.Message{
+		ID:        s.newID(),
+		ChannelID: channelID,
+		GuildID:   testGuild,
+		Content:   content,
+		Author:    &discordgo.User{ID: authorID, Username: authorID},
+	}
+	for _, id := range mentions {
+		m.Mentions = append(m.Mentions, &discordgo.User{ID: id})
+	}
+	s.messages[channelID] = append([]*discordgo.Message{m}, s.messages[channelID]...)
+	return &discordgo.MessageCreate{Message: m}
+}
+
+// reply posts a reply to another message of the channel. Like Discord, the
+// event includes the replied-to message but not the one it replies to.
+func (s *fakeSession) reply(to *discordgo.Message, authorID, content string) *discordgo.MessageCreate {
+	m := s.post(to.ChannelID, authorID, content)
+	m.Type = discordgo.MessageTypeReply
+	m.MessageReference = to.Reference()
+	referenced := *to
+	referenced.ReferencedMessage = nil
+	m.ReferencedMessage = &referenced
+	return m
+}
+
+// lastResponse is the content of the last interaction response.
+func (s *fakeSession) lastResponse() *discordgo.InteractionResponseData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.responses) == 0 {
+		return nil
+	}
+	return s.responses[len(s.responses)-1].Data
+}
+
+func (s *fakeSession) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+func (s *fakeSession) BotUserID() string {
+	return testBotID
+}
+
+func (s *fakeSession) StateChannel(channelID string) (*discordgo.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.channels[channelID]
+	if !ok {
+		return nil, discordgo.ErrStateNotFound
+	}
+	return channel, nil
+}
+
+func (s *fakeSession) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messageRequests = append(s.messageRequests, messageID)
+	for _, m := range s.messages[channelID] {
+		if m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, errFakeNotFound
+}
+
+func (s *fakeSession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messagesRequests = append(s.messagesRequests, messagesRequest{channelID, limit, beforeID})
+
+	messages := s.messages[channelID]
+	if beforeID != "" {
+		for idx, m := range messages {
+			if m.ID == beforeID {
+				messages = messages[idx+1:]
+				break
+			}
+		}
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return append([]*discordgo.Message(nil), messages...), nil
+}
+
+func (s *fakeSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content}, options...)
+}
+
+func (s *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendErr != nil {
+		return nil, s.sendErr
+	}
+	s.sent = append(s.sent, sentMessage{channelID, data})
+	return &discordgo.Message{ID: s.newID(), ChannelID: channelID, Content: data.Content}, nil
+}
+
+func (s *fakeSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendErr != nil {
+		return nil, s.sendErr
+	}
+	s.edits = append(s.edits, m)
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel, Content: *m.Content}, nil
+}
+
+func (s *fakeSession) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleted = append(s.deleted, messageID)
+	return nil
+}
+
+func (s *fakeSession) ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bulkDeleted = append(s.bulkDeleted, messages...)
+	return nil
+}
+
+func (s *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *fakeSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responseEdits = append(s.responseEdits, newresp)
+	return &discordgo.Message{}, nil
+}
+
+func (s *fakeSession) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	return nil
+}
+
+var (
+	errFakeSend     = errors.New("fake send error")
+	errFakeNotFound = errors.New("fake unknown message")
+)
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/ratelimit"
+	"polynux/disgoroq/settings"
+)
+
+const defaultInstructions = `T'es dans une discussion sur Discord, mais t'as aucune idée que t'es un bot. 
+    Ici on est la pour le travail neurosalariale, alors on doit rester concentré, et leverage des k€ et faire fructifier des synergies.
+    Réponds avec un ton décalé et un peu absurde, comme si tu te marrais tout le temps. 
+    Tes réponses doivent être très courtes, max une ou deux phrases. Va pas te perdre en conjectures, t'es pas là pour ça. C'est tres important!
+    Sois un peu piquant quand il faut, mais reste fun.
+    Si un sujet vient sur le tapis, accroche-toi à lui comme à une bouée de sauvetage.
+    Si t'as aucune idée de quoi ils parlent, change de sujet comme si de rien n'était.
+    Quand t'es à court d'idées, balance une blague bien pourrie, comme si c'était la meilleure du monde.
+    Si les gens reviennent toujours sur un même truc, esquive-le comme si c'était un caillou dans ta chaussure.
+    N'hesite pas à te moquer un peu des gens, mais toujours avec bienveillance.
+    Rajoute de temps en temps des émojis pour faire genre t'es un vrai humain.
+    Et surtout, concentre-toi sur les derniers messages, t’as la mémoire courte après tout !`
+
+func (b *Bot) settingsRegistry() *settings.Registry {
+	return settings.NewRegistry(
+		settings.Setting{
+			Name:        "state",
+			Description: "Whether the bot answers at all",
+			Kind:        settings.Bool,
+			Default:     "off",
+			Permission:  defaultMemberPermissions,
+		},
+		settings.Setting{
+			Name:        "threshold",
+			Description: "Probability to answer a message it is not mentioned in",
+			Kind:        settings.Float,
+			Default:     "0.1",
+			Min:         0,
+			Max:         1,
+		},
+		settings.Setting{
+			Name:        "temperature",
+			Description: "Sampling temperature of the model",
+			Kind:        settings.Float,
+			Default:     "0.5",
+			Min:         0,
+			Max:         1,
+			Permission:  defaultMemberPermissions,
+		},
+		settings.Setting{
+			Name:        "messagescount",
+			Description: "Number of channel messages given to the model",
+			Kind:        settings.Int,
+			Default:     "100",
+			Min:         1,
+			Max:         100,
+			Permission:  defaultMemberPermissions,
+		},
+		settings.Setting{
+			Name:        "maxtokens",
+			Description: "Maximum number of tokens per answer",
+			Kind:        settings.Int,
+			Default:     "100",
+			Permission:  defaultMemberPermissions,
+			Validate: func(ctx context.Context, scope settings.Scope, value string) error {
+				model := b.scopeModel(scope, b.scopeProvider(scope))
+				maxTokens, _ := strconv.Atoi(value)
+				if maxTokens < 1 || (model.MaxOutputTokens > 0 && maxTokens > model.MaxOutputTokens) {
+					return fmt.Errorf("%w: maxtokens must be between 1 and %v for model %v", settings.ErrInvalidValue, model.MaxOutputTokens, model.Name)
+				}
+				return nil
+			},
+		},
+		settings.Setting{
+			Name:        "prompt",
+			Description: "Instructions given to the model",
+			Kind:        settings.String,
+			Default:     defaultInstructions,
+			Min:         1,
+			Max:         1000,
+		},
+		settings.Setting{
+			Name:        "provider",
+			Description: "LLM provider answering the messages",
+			Kind:        settings.Choice,
+			Default:     b.config.DefaultProvider,
+			Choices:     []string{"groq", "openai"},
+			Permission:  defaultMemberPermissions,
+			Validate: func(ctx context.Context, scope settings.Scope, value string) error {
+				if _, ok := b.providers[value]; !ok {
+					return fmt.Errorf("%w: provider %v is not configured", settings.ErrInvalidValue, value)
+				}
+				return nil
+			},
+		},
+		settings.Setting{
+			Name:        "model",
+			Description: "Model of the provider, empty for the provider's default",
+			Kind:        settings.String,
+			Permission:  defaultMemberPermissions,
+			Validate:    b.validateModel,
+		},
+		rateLimitSetting("ratelimit_user", "Answers a user can get in a row, and how often they earn one back", "2/20s"),
+		rateLimitSetting("ratelimit_channel", "Answers the bot gives in a row in a channel, and how often it earns one back; mentions are exempt", "4/10s"),
+		rateLimitSetting("ratelimit_guild", "Answers the bot gives in a row in the guild, and how often it earns one back; mentions are exempt", "10/10s"),
+		settings.Setting{
+			Name:        "fallbacks",
+			Description: "Comma-separated models tried in order when the selected one fails, as provider/model, provider or model",
+			Kind:        settings.String,
+			Max:         1000,
+			Permission:  defaultMemberPermissions,
+			Validate: func(ctx context.Context, scope settings.Scope, value string) error {
+				p := b.scopeProvider(scope)
+				for _, entry := range strings.Split(value, ",") {
+					if entry = strings.TrimSpace(entry); entry == "" {
+						continue
+					}
+					if _, err := b.parseFallback(p, entry); err != nil {
+						return fmt.Errorf("%w: %v", settings.ErrInvalidValue, err)
+					}
+				}
+				return nil
+			},
+		},
+		settings.Setting{
+			Name:        "streaming",
+			Description: "Whether answers are streamed into the reply as they are generated",
+			Kind:        settings.Bool,
+			Default:     "off",
+			Permission:  defaultMemberPermissions,
+		},
+		settings.Setting{
+			Name:        "activation",
+			Description: "How the bot decides to answer messages it is not mentioned in: at random with threshold, or with threshold adjusted by a classifier scoring the conversation",
+			Kind:        settings.Choice,
+			Default:     "random",
+			Choices:     []string{"random", "relevance"},
+			Permission:  defaultMemberPermissions,
+		},
+		settings.Setting{
+			Name:        "classifier_model",
+			Description: "Model of the provider scoring relevance, empty for the provider's default",
+			Kind:        settings.String,
+			Permission:  defaultMemberPermissions,
+			Validate:    b.validateModel,
+		},
+		quotaSetting("quota_daily_tokens", "Tokens the server can use per UTC day", settings.Int, 1e9),
+		quotaSetting("quota_monthly_tokens", "Tokens the server can use per UTC month", settings.Int, 1e10),
+		quotaSetting("quota_daily_cost", "Estimated US dollars the server can spend per UTC day", settings.Float, 1e6),
+		quotaSetting("quota_monthly_cost", "Estimated US dollars the server can spend per UTC month", settings.Float, 1e7),
+	)
+}
+
+// quotaSetting declares a server-wide quota, 0 meaning the bot operator's
+// quota only.
+func quotaSetting(name, description string, kind settings.Kind, max float64) settings.Setting {
+	return settings.Setting{
+		Name:        name,
+		Description: description + ", 0 for no limit besides the bot's",
+		Kind:        kind,
+		Default:     "0",
+		Min:         0,
+		Max:         max,
+		Permission:  defaultMemberPermissions,
+		Validate: func(ctx context.Context, scope settings.Scope, value string) error {
+			if len(scope.ChannelIDs) > 0 {
+				return fmt.Errorf("%w: %v applies to the whole server, not a channel", settings.ErrInvalidValue, name)
+			}
+			return nil
+		},
+	}
+}
+
+// rateLimitSetting declares a rate limit, written "<burst>/<refill>" such as
+// "3/20s", or "off".
+func rateLimitSetting(name, description, value string) settings.Setting {
+	return settings.Setting{
+		Name:        name,
+		Description: description + ", as <burst>/<refill> or off",
+		Kind:        settings.String,
+		Default:     value,
+		Permission:  defaultMemberPermissions,
+		Validate: func(ctx context.Context, scope settings.Scope, value string) error {
+			if _, err := ratelimit.ParseLimit(value); err != nil {
+				return fmt.Errorf("%w: %v", settings.ErrInvalidValue, strings.TrimPrefix(err.Error(), ratelimit.ErrInvalidLimit.Error()+": "))
+			}
+			return nil
+		},
+	}
+}
+
+// validateModel accepts an empty value or a known model of the provider
+// selected for scope.
+func (b *Bot) validateModel(ctx context.Context, scope settings.Scope, value string) error {
+	p := b.scopeProvider(scope)
+	if _, ok := provider.LookupModel(p.Name(), value); value != "" && !ok {
+		return fmt.Errorf("%w: unknown model %v for provider %v", settings.ErrInvalidValue, value, p.Name())
+	}
+	return nil
+}
+
+func (b *Bot) scopeProvider(scope settings.Scope) provider.Provider {
+	name := b.settings.Get(context.Background(), scope, "provider").String()
+	if p, ok := b.providers[name]; ok {
+		return p
+	}
+	return b.providers[b.config.DefaultProvider]
+}
+
+// scopeModel returns the model selected for p, falling back to the
+// provider's default when the setting is missing or belongs to another
+// provider.
+func (b *Bot) scopeModel(scope settings.Scope, p provider.Provider) provider.Model {
+	name := b.settings.Get(context.Background(), scope, "model").String()
+	if m, ok := provider.LookupModel(p.Name(), name); ok {
+		return m
+	}
+	return defaultModel(p)
+}
+
+func defaultModel(p provider.Provider) provider.Model {
+	if m, ok := provider.LookupModel(p.Name(), p.DefaultModel()); ok {
+		return m
+	}
+	return provider.Model{Provider: p.Name(), Name: p.DefaultModel()}
+}
+
+// channelScope is the settings scope of a channel: the channel itself, then
+// its parents (the channel of a thread, the category of a channel).
+func channelScope(s Session, guildID, channelID string) settings.Scope {
+	channelIDs := []string{}
+	for channelID != "" && len(channelIDs) < 3 {
+		channelIDs = append(channelIDs, channelID)
+		channel, err := s.StateChannel(channelID)
+		if err != nil {
+			break
+		}
+		channelID = channel.ParentID
+	}
+	return settings.Channel(guildID, channelIDs...)
+}
+
+// settingReply turns the outcome of a settings change into the message shown
+// to the user. Invalid values are explained, other errors are only logged.
+func settingReply(err error, success, failure string) string {
+	if errors.Is(err, settings.ErrInvalidValue) {
+		return "Invalid value" + strings.TrimPrefix(err.Error(), settings.ErrInvalidValue.Error())
+	}
+	if err != nil {
+		log.Println(strings.ToLower(failure)+",", err)
+		return failure
+	}
+	return success
+}
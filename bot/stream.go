@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// streamEditInterval throttles message edits so a fast stream does not hit
+// Discord's rate limits.
+const streamEditInterval = 1500 * time.Millisecond
+
+const streamPlaceholder = "…"
+
+// streamReply posts a placeholder reply and edits it as ask streams the
+// completion, continuing in new messages once it outgrows the first one.
+func streamReply(s Session, channelID string, reference *discordgo.MessageReference, ask func(onDelta func(string)) (string, error)) {
+	reply := newReplyChain(s, channelID, reference)
+	if err := reply.update(streamPlaceholder); err != nil {
+		log.Println("error sending placeholder,", err)
+		return
+	}
+
+	var content strings.Builder
+	lastEdit := time.Now()
+	response, err := ask(func(delta string) {
+		content.WriteString(delta)
+		if time.Since(lastEdit) < streamEditInterval {
+			return
+		}
+		lastEdit = time.Now()
+		if err := reply.update(content.String()); err != nil {
+			log.Println("error editing streamed reply,", err)
+		}
+	})
+	if err != nil || response == "" {
+		response = "There was an error getting the response."
+	}
+	if err := reply.update(response); err != nil {
+		log.Println("error editing streamed reply,", err)
+	}
+}
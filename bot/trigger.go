@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/store"
+	"polynux/disgoroq/trigger"
+)
+
+// probability is the chance to answer m when the bot is not mentioned: the
+// threshold, raised by the most likely trigger m matches. In relevance mode
+// it is then adjusted by how much the conversation invites a reply, unless
+// the rate limits or the quota would stop the bot from answering anyway.
+func (b *Bot) probability(ctx context.Context, s Session, scope settings.Scope, m *discordgo.MessageCreate) float64 {
+	p := b.settings.Get(ctx, scope, "threshold").Float()
+	t, ok := b.triggers.Match(ctx, m.GuildID, m.Content)
+	if ok && t.Probability > p {
+		p = t.Probability
+	}
+
+	if p <= 0 || p >= 1 || b.settings.Get(ctx, scope, "activation").String() != "relevance" {
+		return p
+	}
+	if ok, _ := b.limiter.Check(b.replyRequests(ctx, scope, m, false)...); !ok || b.exceededQuota(ctx, m.GuildID) != "" {
+		return p
+	}
+	score, err := b.relevance(ctx, s, scope, m)
+	if err != nil {
+		log.Printf("guild %v: error scoring relevance, %v", m.GuildID, err)
+	}
+	return combineRelevance(p, score)
+}
+
+func (b *Bot) handleTrigger(s Session, i *discordgo.InteractionCreate) {
+	ctx := context.Background()
+	sub := i.ApplicationCommandData().Options[0]
+	options := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, o := range sub.Options {
+		options[o.Name] = o
+	}
+
+	switch sub.Name {
+	case "add":
+		t := store.Trigger{
+			GuildID:     i.GuildID,
+			Kind:        options["kind"].StringValue(),
+			Pattern:     options["pattern"].StringValue(),
+			Probability: 1,
+		}
+		if probability, ok := options["probability"]; ok {
+			t.Probability = probability.FloatValue()
+		}
+		t, err := b.triggers.Add(ctx, t)
+		respondContent(s, i, triggerReply(err, fmt.Sprintf("Added trigger %v", formatTrigger(t)), "Error adding trigger"))
+	case "remove":
+		id := options["id"].IntValue()
+		err := b.triggers.Remove(ctx, i.GuildID, id)
+		if errors.Is(err, store.ErrNotFound) {
+			respondContent(s, i, fmt.Sprintf("There is no trigger #%v", id))
+			return
+		}
+		respondContent(s, i, triggerReply(err, fmt.Sprintf("Removed trigger #%v", id), "Error removing trigger"))
+	case "list":
+		triggers, err := b.triggers.List(ctx, i.GuildID)
+		if err != nil {
+			log.Println("error listing triggers,", err)
+			respondContent(s, i, "Error listing triggers")
+			return
+		}
+		lines := make([]string, 0, len(triggers))
+		for _, t := range triggers {
+			lines = append(lines, formatTrigger(t))
+		}
+		description := strings.Join(lines, "\n")
+		if description == "" {
+			description = "No triggers, the bot only answers mentions and at random."
+		}
+		respondEmbed(s, i, &discordgo.MessageEmbed{
+			Title:       "Triggers",
+			Description: description,
+		})
+	default:
+		respondContent(s, i, "Wrong option!")
+	}
+}
+
+// triggerReply turns the outcome of a trigger change into the message shown
+// to the user, like settingReply.
+func triggerReply(err error, success, failure string) string {
+	if errors.Is(err, trigger.ErrInvalid) {
+		return "Invalid trigger" + strings.TrimPrefix(err.Error(), trigger.ErrInvalid.Error())
+	}
+	if err != nil {
+		log.Println(strings.ToLower(failure)+",", err)
+		return failure
+	}
+	return success
+}
+
+func formatTrigger(t store.Trigger) string {
+	return fmt.Sprintf("#%v: %v `%v` (%.4g%%)", t.ID, t.Kind, strings.ReplaceAll(t.Pattern, "`", "'"), t.Probability*100)
+}
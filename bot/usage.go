@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/settings"
+	"polynux/disgoroq/store"
+	"polynux/disgoroq/usage"
+)
+
+// usageTopUsers is the number of users listed by /usage.
+const usageTopUsers = 5
+
+// recordUsage accounts for the tokens of a completion answering m. When the
+// provider does not report them, they are estimated from the text.
+func (b *Bot) recordUsage(m *discordgo.Message, c candidate, params *GroqParams, resp *provider.Response) {
+	u := store.Usage{
+		GuildID:          m.GuildID,
+		ChannelID:        m.ChannelID,
+		Provider:         c.provider.Name(),
+		Model:            c.model.Name,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	if m.Author != nil {
+		u.UserID = m.Author.ID
+	}
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		for _, message := range params.request().Messages {
+			u.PromptTokens += provider.EstimateMessageTokens(message)
+		}
+		u.CompletionTokens = provider.EstimateTokens(resp.Content)
+	}
+
+	// The reply is sent even if ctx was canceled meanwhile, so is its usage.
+	if err := b.usage.Record(context.Background(), u); err != nil {
+		log.Printf("guild %v: error recording usage, %v", m.GuildID, err)
+	}
+}
+
+// quota returns the quota of a guild: the operator's, lowered by the
+// guild's own settings.
+func (b *Bot) quota(ctx context.Context, guildID string) usage.Quota {
+	scope := settings.Guild(guildID)
+	return b.config.Quota.Min(usage.Quota{
+		DailyTokens:   b.settings.Get(ctx, scope, "quota_daily_tokens").Int(),
+		MonthlyTokens: b.settings.Get(ctx, scope, "quota_monthly_tokens").Int(),
+		DailyCost:     b.settings.Get(ctx, scope, "quota_daily_cost").Float(),
+		MonthlyCost:   b.settings.Get(ctx, scope, "quota_monthly_cost").Float(),
+	})
+}
+
+// exceededQuota describes the quota a guild reached, and returns "" when it
+// is within its quota. The usage failing to load does not stop the bot.
+func (b *Bot) exceededQuota(ctx context.Context, guildID string) string {
+	quota := b.quota(ctx, guildID)
+	if quota == (usage.Quota{}) {
+		return ""
+	}
+	day, month, err := b.usage.Totals(ctx, guildID)
+	if err != nil {
+		log.Printf("guild %v: error loading usage, %v", guildID, err)
+		return ""
+	}
+	return quota.Exceeded(day, month)
+}
+
+// allowQuota reports whether the guild of m is within its quota. A mention
+// over it is told so.
+func (b *Bot) allowQuota(ctx context.Context, s Session, m *discordgo.MessageCreate, mentioned bool) bool {
+	exceeded := b.exceededQuota(ctx, m.GuildID)
+	if exceeded == "" {
+		return true
+	}
+	if mentioned {
+		b.notice(s, m, "quota", fmt.Sprintf("This server reached its %v, try again later.", exceeded))
+	}
+	return false
+}
+
+func (b *Bot) handleUsage(s Session, i *discordgo.InteractionCreate) {
+	ctx := context.Background()
+	now := time.Now()
+	rows, err := b.store.ListUsage(ctx, i.GuildID, usage.MonthStart(now))
+	if err != nil {
+		log.Println("error listing usage,", err)
+		respondContent(s, i, "Error getting usage")
+		return
+	}
+
+	today := usage.Day(now)
+	var day, month usage.Totals
+	for _, row := range rows {
+		if row.Day == today {
+			day.Add(usage.Of(row))
+		}
+		month.Add(usage.Of(row))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Usage",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Today", Value: formatTotals(day), Inline: true},
+			{Name: "This month", Value: formatTotals(month), Inline: true},
+			{Name: "Quota", Value: b.quota(ctx, i.GuildID).String()},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Days and months are in UTC. Costs are estimated from public prices.",
+		},
+	}
+	if len(rows) > 0 {
+		models := usage.Group(rows, func(u store.Usage) string { return u.Provider + "/" + u.Model })
+		users := usage.Group(rows, func(u store.Usage) string { return u.UserID })
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{
+				Name:  "Models this month",
+				Value: truncate(formatGroups(models, len(models), func(key string) string { return key }), maxEmbedFieldLength),
+			},
+			&discordgo.MessageEmbedField{
+				Name: "Top users this month",
+				Value: truncate(formatGroups(users, usageTopUsers, func(key string) string {
+					return fmt.Sprintf("<@%v>", key)
+				}), maxEmbedFieldLength),
+			},
+		)
+	}
+	respondEmbed(s, i, embed)
+}
+
+func formatTotals(t usage.Totals) string {
+	return fmt.Sprintf("%v tokens in %v requests\n~$%.4f", t.Tokens(), t.Requests, t.Cost)
+}
+
+// formatGroups lists up to limit groups, most tokens first.
+func formatGroups(groups map[string]usage.Totals, limit int, label func(string) string) string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if groups[keys[i]].Tokens() != groups[keys[j]].Tokens() {
+			return groups[keys[i]].Tokens() > groups[keys[j]].Tokens()
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%v: %v tokens, ~$%.4f", label(key), groups[key].Tokens(), groups[key].Cost))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -6,9 +6,38 @@ package db
 
 import ()
 
+type ChannelSetting struct {
+	ID        int64
+	GuildID   string
+	ChannelID string
+	Name      string
+	Value     string
+}
+
 type GuildSetting struct {
 	ID      int64
 	GuildID string
 	Name    string
 	Value   string
 }
+
+type TokenUsage struct {
+	ID               int64
+	GuildID          string
+	ChannelID        string
+	UserID           string
+	Provider         string
+	Model            string
+	Day              string
+	Requests         int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+type Trigger struct {
+	ID          int64
+	GuildID     string
+	Kind        string
+	Pattern     string
+	Probability float64
+}
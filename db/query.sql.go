@@ -9,6 +9,79 @@ import (
 	"context"
 )
 
+const addTokenUsage = `-- name: AddTokenUsage :exec
+INSERT INTO token_usage (guild_id, channel_id, user_id, provider, model, day, requests, prompt_tokens, completion_tokens)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (guild_id, day, channel_id, user_id, provider, model) DO UPDATE SET
+    requests = requests + excluded.requests,
+    prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+    completion_tokens = completion_tokens + excluded.completion_tokens
+`
+
+type AddTokenUsageParams struct {
+	GuildID          string
+	ChannelID        string
+	UserID           string
+	Provider         string
+	Model            string
+	Day              string
+	Requests         int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+func (q *Queries) AddTokenUsage(ctx context.Context, arg AddTokenUsageParams) error {
+	_, err := q.db.ExecContext(ctx, addTokenUsage,
+		arg.GuildID,
+		arg.ChannelID,
+		arg.UserID,
+		arg.Provider,
+		arg.Model,
+		arg.Day,
+		arg.Requests,
+		arg.PromptTokens,
+		arg.CompletionTokens,
+	)
+	return err
+}
+
+const addTrigger = `-- name: AddTrigger :one
+INSERT INTO triggers (guild_id, kind, pattern, probability) VALUES (?, ?, ?, ?) RETURNING id
+`
+
+type AddTriggerParams struct {
+	GuildID     string
+	Kind        string
+	Pattern     string
+	Probability float64
+}
+
+func (q *Queries) AddTrigger(ctx context.Context, arg AddTriggerParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, addTrigger,
+		arg.GuildID,
+		arg.Kind,
+		arg.Pattern,
+		arg.Probability,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteChannelSetting = `-- name: DeleteChannelSetting :exec
+DELETE FROM channel_settings WHERE channel_id = ? AND name = ?
+`
+
+type DeleteChannelSettingParams struct {
+	ChannelID string
+	Name      string
+}
+
+func (q *Queries) DeleteChannelSetting(ctx context.Context, arg DeleteChannelSettingParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChannelSetting, arg.ChannelID, arg.Name)
+	return err
+}
+
 const deleteGuildSetting = `-- name: DeleteGuildSetting :exec
 DELETE FROM guild_settings WHERE guild_id = ? AND name = ?
 `
@@ -23,6 +96,23 @@ func (q *Queries) DeleteGuildSetting(ctx context.Context, arg DeleteGuildSetting
 	return err
 }
 
+const deleteTrigger = `-- name: DeleteTrigger :execrows
+DELETE FROM triggers WHERE guild_id = ? AND id = ?
+`
+
+type DeleteTriggerParams struct {
+	GuildID string
+	ID      int64
+}
+
+func (q *Queries) DeleteTrigger(ctx context.Context, arg DeleteTriggerParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTrigger, arg.GuildID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllGuilds = `-- name: GetAllGuilds :many
 SELECT DISTINCT guild_id FROM guild_settings
 `
@@ -98,6 +188,168 @@ func (q *Queries) GetGuildSettings(ctx context.Context, id int64) ([]GuildSettin
 	return items, nil
 }
 
+const listChannelSettings = `-- name: ListChannelSettings :many
+SELECT id, guild_id, channel_id, name, value FROM channel_settings WHERE guild_id = ?
+`
+
+func (q *Queries) ListChannelSettings(ctx context.Context, guildID string) ([]ChannelSetting, error) {
+	rows, err := q.db.QueryContext(ctx, listChannelSettings, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChannelSetting
+	for rows.Next() {
+		var i ChannelSetting
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuildID,
+			&i.ChannelID,
+			&i.Name,
+			&i.Value,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGuildSettings = `-- name: ListGuildSettings :many
+SELECT id, guild_id, name, value FROM guild_settings WHERE guild_id = ?
+`
+
+func (q *Queries) ListGuildSettings(ctx context.Context, guildID string) ([]GuildSetting, error) {
+	rows, err := q.db.QueryContext(ctx, listGuildSettings, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GuildSetting
+	for rows.Next() {
+		var i GuildSetting
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuildID,
+			&i.Name,
+			&i.Value,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTokenUsage = `-- name: ListTokenUsage :many
+SELECT id, guild_id, channel_id, user_id, provider, model, day, requests, prompt_tokens, completion_tokens FROM token_usage WHERE guild_id = ? AND day >= ?
+`
+
+type ListTokenUsageParams struct {
+	GuildID string
+	Day     string
+}
+
+func (q *Queries) ListTokenUsage(ctx context.Context, arg ListTokenUsageParams) ([]TokenUsage, error) {
+	rows, err := q.db.QueryContext(ctx, listTokenUsage, arg.GuildID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TokenUsage
+	for rows.Next() {
+		var i TokenUsage
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuildID,
+			&i.ChannelID,
+			&i.UserID,
+			&i.Provider,
+			&i.Model,
+			&i.Day,
+			&i.Requests,
+			&i.PromptTokens,
+			&i.CompletionTokens,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTriggers = `-- name: ListTriggers :many
+SELECT id, guild_id, kind, pattern, probability FROM triggers WHERE guild_id = ? ORDER BY id
+`
+
+func (q *Queries) ListTriggers(ctx context.Context, guildID string) ([]Trigger, error) {
+	rows, err := q.db.QueryContext(ctx, listTriggers, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Trigger
+	for rows.Next() {
+		var i Trigger
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuildID,
+			&i.Kind,
+			&i.Pattern,
+			&i.Probability,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChannelSetting = `-- name: SetChannelSetting :exec
+INSERT OR REPLACE INTO channel_settings (guild_id, channel_id, name, value) VALUES (?, ?, ?, ?)
+`
+
+type SetChannelSettingParams struct {
+	GuildID   string
+	ChannelID string
+	Name      string
+	Value     string
+}
+
+func (q *Queries) SetChannelSetting(ctx context.Context, arg SetChannelSettingParams) error {
+	_, err := q.db.ExecContext(ctx, setChannelSetting,
+		arg.GuildID,
+		arg.ChannelID,
+		arg.Name,
+		arg.Value,
+	)
+	return err
+}
+
 const setGuildSetting = `-- name: SetGuildSetting :exec
 INSERT OR REPLACE INTO guild_settings (guild_id, name, value) VALUES (?, ?, ?)
 `
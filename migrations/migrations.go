@@ -0,0 +1,169 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Dialect is a database flavour with its own set of migrations, kept in the
+// directory of the same name.
+type Dialect struct {
+	dir string
+	// recordMigration inserts a row in schema_migrations.
+	recordMigration string
+}
+
+var (
+	SQLite = Dialect{
+		dir:             "sqlite",
+		recordMigration: "INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+	}
+	Postgres = Dialect{
+		dir:             "postgres",
+		recordMigration: "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+	}
+)
+
+// Migration is an up-only schema change. Files are named
+// <version>_<name>.sql and applied in version order.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// All returns the embedded migrations of a dialect sorted by version.
+func All(d Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	for _, entry := range entries {
+		base := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %v: expected <version>_<name>.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v: invalid version: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(files, path.Join(d.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			SQL:     string(content),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %v", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Run applies the migrations that are not recorded in schema_migrations yet
+// and returns them.
+func Run(ctx context.Context, db *sql.DB, d Dialect) ([]Migration, error) {
+	migrations, err := All(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := apply(ctx, db, d, m); err != nil {
+			return done, fmt.Errorf("migration %v_%v: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func apply(ctx context.Context, db *sql.DB, d Dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements(m.SQL) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, d.recordMigration, m.Version, m.Name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements splits a migration into its statements, as libsql only runs the
+// first one of a multi-statement Exec. Migrations must not use semicolons
+// other than as separators.
+func statements(sql string) []string {
+	var list []string
+	for _, statement := range strings.Split(sql, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			list = append(list, statement)
+		}
+	}
+	return list
+}
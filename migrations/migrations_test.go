@@ -0,0 +1,179 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/tursodatabase/go-libsql"
+)
+
+// openTestDB opens an empty libsql database in a temporary file.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("libsql", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, statements ...string) {
+	t.Helper()
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%v: %v", statement, err)
+		}
+	}
+}
+
+// indexes returns the names of the indexes of a table.
+func indexes(t *testing.T, db *sql.DB, table string) map[string]bool {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	names := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// settings returns the guild_settings rows as "guild_id/name=value".
+func settings(t *testing.T, db *sql.DB) map[string]bool {
+	t.Helper()
+	rows, err := db.Query("SELECT guild_id, name, value FROM guild_settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	values := map[string]bool{}
+	for rows.Next() {
+		var guildID, name, value string
+		if err := rows.Scan(&guildID, &name, &value); err != nil {
+			t.Fatal(err)
+		}
+		values[guildID+"/"+name+"="+value] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func checkIndexes(t *testing.T, db *sql.DB) {
+	t.Helper()
+	for table, names := range map[string][]string{
+		"guild_settings":   {"idx_guild_settings_guild_id_name"},
+		"channel_settings": {"idx_channel_settings_channel_id_name", "idx_channel_settings_guild_id"},
+		"token_usage":      {"idx_token_usage_guild_id_day_key"},
+		"triggers":         {"idx_triggers_guild_id"},
+	} {
+		got := indexes(t, db, table)
+		for _, name := range names {
+			if !got[name] {
+				t.Errorf("missing index %v on %v", name, table)
+			}
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	for _, d := range []Dialect{SQLite, Postgres} {
+		migrations, err := All(d)
+		if err != nil {
+			t.Fatalf("%v: %v", d.dir, err)
+		}
+		for idx, m := range migrations {
+			if m.Version != idx+1 {
+				t.Errorf("%v: migration %v_%v should be version %v", d.dir, m.Version, m.Name, idx+1)
+			}
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	got := statements("CREATE TABLE a (id INTEGER);\n\n-- comment\nCREATE INDEX b ON a(id);\n")
+	if len(got) != 2 || got[0] != "CREATE TABLE a (id INTEGER)" || got[1] != "-- comment\nCREATE INDEX b ON a(id)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	applied, err := Run(ctx, db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := All(SQLite)
+	if len(applied) != len(all) {
+		t.Errorf("applied %v migrations, want %v", len(applied), len(all))
+	}
+	checkIndexes(t, db)
+
+	applied, err = Run(ctx, db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("applied %v migrations again", len(applied))
+	}
+}
+
+func TestRunOnBaselineDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	// Before migrations, libsql ran only the first statement of schema.sql:
+	// guild_settings had no unique index, and INSERT OR REPLACE added a row
+	// each time a setting changed.
+	exec(t, db,
+		`CREATE TABLE IF NOT EXISTS guild_settings (
+			id INTEGER PRIMARY KEY,
+			guild_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			value TEXT NOT NULL
+		)`,
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g1', 'enabled', 'false')",
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g1', 'enabled', 'true')",
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g1', 'threshold', '0.1')",
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g2', 'enabled', 'false')",
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g1', 'last_message', '1')",
+		"INSERT INTO guild_settings (guild_id, name, value) VALUES ('g1', 'last_message', '2')",
+	)
+
+	for run := 0; run < 2; run++ {
+		if _, err := Run(ctx, db, SQLite); err != nil {
+			t.Fatalf("run %v: %v", run, err)
+		}
+	}
+	checkIndexes(t, db)
+
+	want := map[string]bool{"g1/enabled=true": true, "g1/threshold=0.1": true, "g2/enabled=false": true}
+	if got := settings(t, db); len(got) != len(want) {
+		t.Errorf("got settings %v, want %v", got, want)
+	} else {
+		for value := range want {
+			if !got[value] {
+				t.Errorf("got settings %v, want %v", got, want)
+				break
+			}
+		}
+	}
+}
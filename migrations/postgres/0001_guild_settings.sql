@@ -1,10 +1,9 @@
 CREATE TABLE IF NOT EXISTS guild_settings (
-    id INTEGER PRIMARY KEY,
+    id BIGSERIAL PRIMARY KEY,
     guild_id TEXT NOT NULL,
     name TEXT NOT NULL,
     value TEXT NOT NULL
 );
 
-CREATE UNIQUE INDEX IF NOT EXISTS idx_guild_settings_guild_id_name 
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guild_settings_guild_id_name
 ON guild_settings(guild_id, name);
-
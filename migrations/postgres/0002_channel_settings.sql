@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS channel_settings (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    channel_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_settings_channel_id_name
+ON channel_settings(channel_id, name);
+
+CREATE INDEX IF NOT EXISTS idx_channel_settings_guild_id
+ON channel_settings(guild_id);
@@ -0,0 +1,2 @@
+-- The time of the last answer is no longer stored as a setting.
+DELETE FROM guild_settings WHERE name = 'last_message';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS guild_settings (
+    id INTEGER PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL
+);
+
+-- Databases created from schema.sql never got the unique index, as libsql
+-- ran only its first statement, and INSERT OR REPLACE kept adding rows.
+-- Keep the latest row of each setting.
+DELETE FROM guild_settings
+WHERE id NOT IN (SELECT MAX(id) FROM guild_settings GROUP BY guild_id, name);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guild_settings_guild_id_name 
+ON guild_settings(guild_id, name);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS triggers (
+    id INTEGER PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    probability REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_triggers_guild_id
+ON triggers(guild_id);
@@ -0,0 +1,2 @@
+-- Replies to the bot are answered like mentions, reply triggers have no use.
+DELETE FROM triggers WHERE kind = 'reply';
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("provider is unavailable")
+
+// Breaker is a circuit breaker: after Threshold temporary failures in a row
+// it rejects calls for Cooldown, then lets them through again to find out
+// whether the provider recovered.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may be made.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.now().Before(b.openUntil)
+}
+
+// Record accounts for the outcome of a call. Only temporary failures count:
+// any other answer means the provider is up.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !IsTemporary(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openUntil = b.now().Add(b.Cooldown)
+	}
+}
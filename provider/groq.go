@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/conneroisu/groq-go"
+)
+
+type Groq struct {
+	client *groq.Client
+	model  string
+}
+
+// NewGroq creates a Groq provider sending its requests with httpClient, or
+// http.DefaultClient when nil. An empty baseURL uses Groq's API, another one
+// points the client at a compatible server such as a test double.
+func NewGroq(apiKey, baseURL string, httpClient *http.Client) (*Groq, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	opts := []groq.Opts{
+		groq.WithClient(&http.Client{
+			Transport: recordingTransport{transport},
+			Timeout:   httpClient.Timeout,
+		}),
+	}
+	if baseURL != "" {
+		opts = append(opts, groq.WithBaseURL(baseURL))
+	}
+	client, err := groq.NewClient(apiKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Groq{
+		client: client,
+		model:  string(groq.Llama318BInstant),
+	}, nil
+}
+
+func (g *Groq) Name() string {
+	return "groq"
+}
+
+func (g *Groq) DefaultModel() string {
+	return g.model
+}
+
+func (g *Groq) request(req Request) groq.ChatCompletionRequest {
+	model := req.Model
+	if model == "" {
+		model = g.model
+	}
+
+	messages := make([]groq.ChatCompletionMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = groq.ChatCompletionMessage{
+			Role:    groq.Role(m.Role),
+			Content: m.Content,
+		}
+	}
+
+	return groq.ChatCompletionRequest{
+		Model:       groq.Model(model),
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+}
+
+func (g *Groq) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	ctx, rec := recordResponse(ctx)
+	resp, err := g.client.CreateChatCompletion(ctx, g.request(req))
+	if err != nil {
+		return nil, rec.wrap(err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, ErrNoChoices
+	}
+
+	return &Response{
+		Model:   resp.Model,
+		Content: resp.Choices[0].Message.Content,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func (g *Groq) ChatCompletionStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	request := g.request(req)
+	request.StreamOptions = &groq.StreamOptions{IncludeUsage: true}
+
+	ctx, rec := recordResponse(ctx)
+	stream, err := g.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, rec.wrap(err)
+	}
+	defer stream.Close()
+
+	resp := &Response{Model: string(request.Model)}
+	var content strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.Usage != nil {
+			resp.Usage = Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		onDelta(chunk.Choices[0].Delta.Content)
+	}
+
+	resp.Content = content.String()
+	return resp, nil
+}
+
+// responseRecord keeps the status and headers of the response to a request,
+// which groq-go does not expose on its errors.
+type responseRecord struct {
+	status int
+	header http.Header
+}
+
+type responseRecordKey struct{}
+
+func recordResponse(ctx context.Context) (context.Context, *responseRecord) {
+	rec := &responseRecord{}
+	return context.WithValue(ctx, responseRecordKey{}, rec), rec
+}
+
+// wrap turns the error of a request answered with a failure status into an
+// APIError.
+func (rec *responseRecord) wrap(err error) error {
+	if rec.status < http.StatusBadRequest {
+		return err
+	}
+
+	message := err.Error()
+	var groqErr *groq.APIError
+	if errors.As(err, &groqErr) {
+		message = groqErr.Message
+	}
+	return &APIError{
+		StatusCode: rec.status,
+		Message:    message,
+		RetryAfter: parseRetryAfter(rec.header.Get("Retry-After")),
+		Err:        err,
+	}
+}
+
+// recordingTransport fills the responseRecord of the requests it sends.
+type recordingTransport struct {
+	base http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if rec, ok := req.Context().Value(responseRecordKey{}).(*responseRecord); ok && resp != nil {
+		rec.status = resp.StatusCode
+		rec.header = resp.Header
+	}
+	return resp, err
+}
@@ -0,0 +1,130 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conneroisu/groq-go"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/provider/providertest"
+)
+
+func newGroq(t *testing.T, replies ...providertest.Reply) (*provider.Groq, *providertest.Server) {
+	t.Helper()
+
+	server := providertest.NewServer(replies...)
+	t.Cleanup(server.Close)
+	p, err := provider.NewGroq("test-key", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, server
+}
+
+var testRequest = provider.Request{
+	Model: "llama-3.1-8b-instant",
+	Messages: []provider.Message{
+		{Role: provider.RoleSystem, Content: "Be brief"},
+		{Role: provider.RoleUser, Content: "alice: hi"},
+	},
+	MaxTokens:   50,
+	Temperature: 0.5,
+}
+
+func TestGroqChatCompletion(t *testing.T) {
+	p, server := newGroq(t, providertest.Reply{Content: "hello", PromptTokens: 12, CompletionTokens: 3})
+
+	resp, err := p.ChatCompletion(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || resp.Model != testRequest.Model {
+		t.Errorf("got %+v", resp)
+	}
+	if want := (provider.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}); resp.Usage != want {
+		t.Errorf("usage is %+v, want %+v", resp.Usage, want)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("server got %v requests", len(requests))
+	}
+	req := requests[0]
+	if req.MaxTokens != 50 || len(req.Messages) != 2 || req.Messages[1].Content != "alice: hi" {
+		t.Errorf("server got %+v", req)
+	}
+}
+
+func TestGroqChatCompletionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  providertest.Reply
+		status int
+	}{
+		{"server error", providertest.Reply{Status: http.StatusInternalServerError, Error: "oops"}, http.StatusInternalServerError},
+		{"rate limited", providertest.Reply{Status: http.StatusTooManyRequests, Error: "slow down", RetryAfter: "2"}, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newGroq(t, tt.reply)
+
+			_, err := p.ChatCompletion(context.Background(), testRequest)
+			var apiErr *groq.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want an API error", err)
+			}
+			if apiErr.HTTPStatusCode != tt.status || apiErr.Message != tt.reply.Error {
+				t.Errorf("got %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestGroqRetryAfter(t *testing.T) {
+	p, _ := newGroq(t, providertest.Reply{Status: http.StatusTooManyRequests, Error: "slow down", RetryAfter: "2"})
+
+	_, err := p.ChatCompletion(context.Background(), testRequest)
+	var apiErr *provider.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want an API error", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 2*time.Second || apiErr.Message != "slow down" {
+		t.Errorf("got %+v", apiErr)
+	}
+}
+
+func TestGroqChatCompletionNoChoices(t *testing.T) {
+	p, _ := newGroq(t, providertest.Reply{NoChoices: true})
+
+	_, err := p.ChatCompletion(context.Background(), testRequest)
+	if !errors.Is(err, provider.ErrNoChoices) {
+		t.Fatalf("got error %v, want ErrNoChoices", err)
+	}
+}
+
+func TestGroqChatCompletionStream(t *testing.T) {
+	p, server := newGroq(t, providertest.Reply{Deltas: []string{"hel", "lo"}, PromptTokens: 12, CompletionTokens: 2})
+
+	var deltas []string
+	resp, err := p.ChatCompletionStream(context.Background(), testRequest, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || strings.Join(deltas, "|") != "hel|lo" {
+		t.Errorf("got %q in deltas %q", resp.Content, deltas)
+	}
+	if resp.Usage.TotalTokens != 14 {
+		t.Errorf("usage is %+v", resp.Usage)
+	}
+	if !server.Requests()[0].Stream {
+		t.Error("the request was not streamed")
+	}
+}
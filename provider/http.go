@@ -0,0 +1,13 @@
+package provider
+
+import (
+	"net/http"
+)
+
+// NewHTTPClient returns an HTTP client meant to be shared by the providers,
+// so completions reuse one pool of keep-alive connections.
+func NewHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,65 @@
+package provider
+
+import "strings"
+
+// Model describes a chat model offered by a provider, its limits and its
+// price.
+type Model struct {
+	Provider        string
+	Name            string
+	ContextWindow   int
+	MaxOutputTokens int
+	// InputPrice and OutputPrice are in US dollars per million prompt and
+	// completion tokens.
+	InputPrice  float64
+	OutputPrice float64
+}
+
+// Cost is the price in US dollars of a completion using the given tokens.
+func (m Model) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*m.InputPrice + float64(completionTokens)*m.OutputPrice) / 1e6
+}
+
+var Models = []Model{
+	{Provider: "groq", Name: "llama-3.1-8b-instant", ContextWindow: 131072, MaxOutputTokens: 8000, InputPrice: 0.05, OutputPrice: 0.08},
+	{Provider: "groq", Name: "llama-3.1-70b-versatile", ContextWindow: 131072, MaxOutputTokens: 8000, InputPrice: 0.59, OutputPrice: 0.79},
+	{Provider: "groq", Name: "llama3-8b-8192", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 0.05, OutputPrice: 0.08},
+	{Provider: "groq", Name: "llama3-70b-8192", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 0.59, OutputPrice: 0.79},
+	{Provider: "groq", Name: "llama3-groq-8b-8192-tool-use-preview", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 0.19, OutputPrice: 0.19},
+	{Provider: "groq", Name: "llama3-groq-70b-8192-tool-use-preview", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 0.89, OutputPrice: 0.89},
+	{Provider: "groq", Name: "gemma2-9b-it", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 0.20, OutputPrice: 0.20},
+	{Provider: "groq", Name: "mixtral-8x7b-32768", ContextWindow: 32768, MaxOutputTokens: 32768, InputPrice: 0.24, OutputPrice: 0.24},
+}
+
+// RegisterModel adds a model to the catalog, replacing any existing entry
+// with the same provider and name.
+func RegisterModel(m Model) {
+	for i := range Models {
+		if Models[i].Provider == m.Provider && Models[i].Name == m.Name {
+			Models[i] = m
+			return
+		}
+	}
+	Models = append(Models, m)
+}
+
+func LookupModel(providerName, name string) (Model, bool) {
+	for _, m := range Models {
+		if m.Provider == providerName && m.Name == name {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// SearchModels returns the models of a provider whose name contains query.
+func SearchModels(providerName, query string) []Model {
+	query = strings.ToLower(query)
+	found := []Model{}
+	for _, m := range Models {
+		if m.Provider == providerName && strings.Contains(strings.ToLower(m.Name), query) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
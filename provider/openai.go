@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI talks to any server implementing the OpenAI chat completions API.
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAI creates a provider for the server at baseURL, sending its
+// requests with client, or http.DefaultClient when nil.
+func NewOpenAI(baseURL, apiKey, model string, client *http.Client) *OpenAI {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenAI{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  client,
+	}
+}
+
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+func (o *OpenAI) DefaultModel() string {
+	return o.model
+}
+
+type openAIMessage struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   float32              `json:"temperature"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u openAIUsage) usage() Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o *OpenAI) request(req Request) openAIRequest {
+	model := req.Model
+	if model == "" {
+		model = o.model
+	}
+
+	body := openAIRequest{
+		Model:       model,
+		Messages:    make([]openAIMessage, len(req.Messages)),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	for i, m := range req.Messages {
+		body.Messages[i] = openAIMessage{Role: m.Role, Content: m.Content}
+	}
+	return body
+}
+
+func (o *OpenAI) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	res, err := o.do(ctx, o.request(req))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var resp openAIResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, ErrNoChoices
+	}
+
+	return &Response{
+		Model:   resp.Model,
+		Content: resp.Choices[0].Message.Content,
+		Usage:   resp.Usage.usage(),
+	}, nil
+}
+
+func (o *OpenAI) ChatCompletionStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	body := o.request(req)
+	body.Stream = true
+	body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	res, err := o.do(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resp := &Response{Model: body.Model}
+	var content strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("decoding stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			resp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			resp.Usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		onDelta(chunk.Choices[0].Delta.Content)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	resp.Content = content.String()
+	return resp, nil
+}
+
+func (o *OpenAI) do(ctx context.Context, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		apiErr := &APIError{
+			StatusCode: res.StatusCode,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+		raw, _ := io.ReadAll(res.Body)
+		var errBody openAIError
+		if json.Unmarshal(raw, &errBody) == nil && errBody.Error.Message != "" {
+			apiErr.Message = errBody.Error.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		return nil, apiErr
+	}
+	return res, nil
+}
@@ -0,0 +1,61 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/provider/providertest"
+)
+
+func newOpenAI(t *testing.T, replies ...providertest.Reply) *provider.OpenAI {
+	t.Helper()
+
+	server := providertest.NewServer(replies...)
+	t.Cleanup(server.Close)
+	return provider.NewOpenAI(server.URL+"/", "test-key", "local-model", nil)
+}
+
+func TestOpenAIChatCompletion(t *testing.T) {
+	p := newOpenAI(t, providertest.Reply{Content: "hello", PromptTokens: 12, CompletionTokens: 3})
+
+	resp, err := p.ChatCompletion(context.Background(), provider.Request{Messages: testRequest.Messages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || resp.Model != "local-model" || resp.Usage.TotalTokens != 15 {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestOpenAIChatCompletionErrors(t *testing.T) {
+	p := newOpenAI(t,
+		providertest.Reply{Status: http.StatusTooManyRequests, Error: "slow down"},
+		providertest.Reply{NoChoices: true},
+	)
+
+	_, err := p.ChatCompletion(context.Background(), testRequest)
+	var apiErr *provider.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "slow down" {
+		t.Errorf("got error %v, want a 429 API error", err)
+	}
+
+	_, err = p.ChatCompletion(context.Background(), testRequest)
+	if !errors.Is(err, provider.ErrNoChoices) {
+		t.Errorf("got error %v, want ErrNoChoices", err)
+	}
+}
+
+func TestOpenAIChatCompletionStream(t *testing.T) {
+	p := newOpenAI(t, providertest.Reply{Deltas: []string{"hel", "lo"}, PromptTokens: 12, CompletionTokens: 2})
+
+	resp, err := p.ChatCompletionStream(context.Background(), testRequest, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || resp.Usage.TotalTokens != 14 {
+		t.Errorf("got %+v", resp)
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+var ErrNoChoices = errors.New("provider returned no choices")
+
+// APIError is returned when a provider's server answers with a non-2xx
+// status.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is how long the server asked to wait before retrying, zero
+	// when it did not say.
+	RetryAfter time.Duration
+	// Err is the error of the underlying client library, if any.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status code: %d, message: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+type Message struct {
+	Role    Role
+	Content string
+}
+
+type Request struct {
+	// Model is the model name understood by the provider. An empty model
+	// means the provider's default model.
+	Model       string
+	Messages    []Message
+	MaxTokens   int
+	Temperature float32
+}
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+type Response struct {
+	Model   string
+	Content string
+	Usage   Usage
+}
+
+// Provider is a chat completion backend.
+type Provider interface {
+	Name() string
+	DefaultModel() string
+	ChatCompletion(ctx context.Context, req Request) (*Response, error)
+	// ChatCompletionStream is like ChatCompletion but calls onDelta with
+	// each piece of content as it is generated. The returned response holds
+	// the whole content.
+	ChatCompletionStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error)
+}
@@ -0,0 +1,180 @@
+// Package providertest provides a fake chat completions server speaking the
+// OpenAI-compatible API used by Groq, for tests that must not reach the
+// network.
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Reply is what the server answers to one chat completion request.
+type Reply struct {
+	// Status is the HTTP status code, 200 when zero. Any other status
+	// answers with an API error carrying Error.
+	Status int
+	Error  string
+	// RetryAfter is sent as the Retry-After header when set.
+	RetryAfter string
+
+	Content string
+	// Deltas are the pieces a streamed reply is sent in, Content in a
+	// single piece when empty.
+	Deltas []string
+	// NoChoices answers with an empty choices list.
+	NoChoices bool
+
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Message is a chat message received by the server.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is a chat completion request received by the server.
+type Request struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float32   `json:"temperature"`
+	Stream      bool      `json:"stream"`
+}
+
+// Server is a fake chat completions server. Its URL is the base URL to give
+// to a client: requests are accepted on URL + "/chat/completions".
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+}
+
+// NewServer starts a server answering with replies in order, repeating the
+// last one once they run out.
+func NewServer(replies ...Reply) *Server {
+	s := &Server{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) next(req Request) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if len(s.replies) == 0 {
+		return Reply{}
+	}
+	reply := s.replies[0]
+	if len(s.replies) > 1 {
+		s.replies = s.replies[1:]
+	}
+	return reply
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reply := s.next(req)
+	if reply.RetryAfter != "" {
+		w.Header().Set("Retry-After", reply.RetryAfter)
+	}
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	if req.Stream {
+		writeStream(w, req, reply)
+		return
+	}
+
+	response := map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"model":   req.Model,
+		"choices": []any{},
+		"usage":   usage(reply),
+	}
+	if !reply.NoChoices {
+		response["choices"] = []any{map[string]any{
+			"index":         0,
+			"message":       Message{Role: "assistant", Content: reply.Content},
+			"finish_reason": "stop",
+		}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeStream(w http.ResponseWriter, req Request, reply Reply) {
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	deltas := reply.Deltas
+	if len(deltas) == 0 && !reply.NoChoices {
+		deltas = []string{reply.Content}
+	}
+
+	chunk := func(choices []any, usage any) {
+		data, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion.chunk",
+			"model":   req.Model,
+			"choices": choices,
+			"usage":   usage,
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for _, delta := range deltas {
+		chunk([]any{map[string]any{
+			"index": 0,
+			"delta": map[string]string{"content": delta},
+		}}, nil)
+	}
+	chunk([]any{}, usage(reply))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func usage(reply Reply) map[string]int {
+	return map[string]int{
+		"prompt_tokens":     reply.PromptTokens,
+		"completion_tokens": reply.CompletionTokens,
+		"total_tokens":      reply.PromptTokens + reply.CompletionTokens,
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{
+			"message": message,
+			"type":    http.StatusText(status),
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Resilient wraps a Provider to retry temporary failures with exponential
+// backoff, and to fail fast while its circuit breaker is open.
+type Resilient struct {
+	Provider
+	retry   RetryPolicy
+	breaker *Breaker
+}
+
+func NewResilient(p Provider, retry RetryPolicy, breaker *Breaker) *Resilient {
+	return &Resilient{
+		Provider: p,
+		retry:    retry,
+		breaker:  breaker,
+	}
+}
+
+func (r *Resilient) ChatCompletion(ctx context.Context, req Request) (*Response, error) {
+	var resp *Response
+	err := r.do(ctx, func() (err error) {
+		resp, err = r.Provider.ChatCompletion(ctx, req)
+		return err
+	}, nil)
+	return resp, err
+}
+
+// ChatCompletionStream retries a stream only while nothing was streamed yet,
+// as onDelta cannot take back what it was given.
+func (r *Resilient) ChatCompletionStream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	var resp *Response
+	streamed := false
+	err := r.do(ctx, func() (err error) {
+		resp, err = r.Provider.ChatCompletionStream(ctx, req, func(delta string) {
+			streamed = true
+			onDelta(delta)
+		})
+		return err
+	}, func() bool {
+		return !streamed
+	})
+	return resp, err
+}
+
+func (r *Resilient) do(ctx context.Context, call func() error, canRetry func() bool) error {
+	for attempt := 0; ; attempt++ {
+		if !r.breaker.Allow() {
+			return fmt.Errorf("%w: %v", ErrCircuitOpen, r.Name())
+		}
+
+		err := call()
+		r.breaker.Record(err)
+		if err == nil {
+			return nil
+		}
+
+		delay, ok := r.retry.delay(err, attempt)
+		if !ok || (canRetry != nil && !canRetry()) {
+			return err
+		}
+		log.Printf("%v completion failed, retrying in %v: %v", r.Name(), delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
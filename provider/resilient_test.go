@@ -0,0 +1,63 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/provider/providertest"
+)
+
+var testRetryPolicy = provider.RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+}
+
+func TestResilientRetries(t *testing.T) {
+	p, server := newGroq(t,
+		providertest.Reply{Status: http.StatusTooManyRequests, Error: "slow down"},
+		providertest.Reply{Status: http.StatusBadGateway, Error: "down"},
+		providertest.Reply{Content: "hello"},
+	)
+	r := provider.NewResilient(p, testRetryPolicy, provider.NewBreaker(5, time.Minute))
+
+	resp, err := r.ChatCompletion(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || len(server.Requests()) != 3 {
+		t.Errorf("got %q after %v requests", resp.Content, len(server.Requests()))
+	}
+}
+
+func TestResilientDoesNotRetryPermanentErrors(t *testing.T) {
+	p, server := newGroq(t, providertest.Reply{Status: http.StatusBadRequest, Error: "bad"})
+	r := provider.NewResilient(p, testRetryPolicy, provider.NewBreaker(5, time.Minute))
+
+	if _, err := r.ChatCompletion(context.Background(), testRequest); err == nil {
+		t.Fatal("no error")
+	}
+	if len(server.Requests()) != 1 {
+		t.Errorf("sent %v requests, want 1", len(server.Requests()))
+	}
+}
+
+func TestResilientCircuitBreaker(t *testing.T) {
+	p, server := newGroq(t, providertest.Reply{Status: http.StatusServiceUnavailable, Error: "down"})
+	r := provider.NewResilient(p, testRetryPolicy, provider.NewBreaker(3, time.Minute))
+
+	if _, err := r.ChatCompletion(context.Background(), testRequest); err == nil {
+		t.Fatal("no error")
+	}
+	_, err := r.ChatCompletion(context.Background(), testRequest)
+	if !errors.Is(err, provider.ErrCircuitOpen) {
+		t.Fatalf("got error %v, want ErrCircuitOpen", err)
+	}
+	if len(server.Requests()) != 3 {
+		t.Errorf("sent %v requests, want 3", len(server.Requests()))
+	}
+}
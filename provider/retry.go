@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how a failed completion is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries, the first one included.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each
+	// following one up to MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between two tries. A server asking to wait
+	// longer is not retried, so the caller can fail over instead.
+	MaxDelay time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// delay returns how long to wait before retrying after attempt (counted from
+// zero) failed with err, and false when it should not be retried.
+func (p RetryPolicy) delay(err error, attempt int) (time.Duration, bool) {
+	if attempt+1 >= p.MaxAttempts || !IsTemporary(err) {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= p.MaxDelay
+	}
+
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// Full jitter on half of the delay, so bots sharing a key do not retry
+	// in lockstep.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)), true
+}
+
+// IsTemporary reports whether a completion failing with err may succeed if
+// tried again: rate limits, server errors and network failures.
+func IsTemporary(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests}
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		delay, ok := policy.delay(rateLimited, attempt)
+		if !ok || delay < max/2 || delay > max {
+			t.Errorf("attempt %v waits %v (%v), want between %v and %v", attempt, delay, ok, max/2, max)
+		}
+	}
+	if _, ok := policy.delay(rateLimited, 2); ok {
+		t.Error("retried past MaxAttempts")
+	}
+
+	if delay, ok := policy.delay(&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 700 * time.Millisecond}, 0); !ok || delay != 700*time.Millisecond {
+		t.Errorf("Retry-After of 700ms waits %v (%v)", delay, ok)
+	}
+	if _, ok := policy.delay(&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}, 0); ok {
+		t.Error("retried although the server asked to wait longer than MaxDelay")
+	}
+	if _, ok := policy.delay(&APIError{StatusCode: http.StatusBadRequest}, 0); ok {
+		t.Error("retried a bad request")
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{&APIError{StatusCode: http.StatusBadGateway}, true},
+		{&APIError{StatusCode: http.StatusUnauthorized}, false},
+		{ErrNoChoices, false},
+		{context.Canceled, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTemporary(tt.err); got != tt.want {
+			t.Errorf("IsTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parsed 3 as %v", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parsed %v as %v", date, got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parsed soon as %v", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	unavailable := &APIError{StatusCode: http.StatusServiceUnavailable}
+
+	b.Record(unavailable)
+	b.Record(nil)
+	b.Record(unavailable)
+	if !b.Allow() {
+		t.Fatal("opened without consecutive failures")
+	}
+
+	b.Record(unavailable)
+	if b.Allow() {
+		t.Fatal("still closed after two failures in a row")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("still open after the cooldown")
+	}
+	b.Record(unavailable)
+	if b.Allow() {
+		t.Fatal("a failed trial did not reopen the breaker")
+	}
+}
@@ -0,0 +1,23 @@
+package provider
+
+import "unicode/utf8"
+
+const (
+	// charsPerToken deliberately underestimates how much text fits in a
+	// token so that estimates err on the side of leaving room.
+	charsPerToken = 3
+	// messageOverhead accounts for the role and separators each chat
+	// message adds to the prompt.
+	messageOverhead = 4
+)
+
+// EstimateTokens approximates the number of tokens in s without needing the
+// model's tokenizer.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessageTokens approximates the prompt cost of a chat message.
+func EstimateMessageTokens(m Message) int {
+	return EstimateTokens(m.Content) + messageOverhead
+}
@@ -4,6 +4,9 @@ SELECT * FROM guild_settings WHERE id = ?;
 -- name: GetGuildSetting :one
 SELECT value FROM guild_settings WHERE guild_id = ? AND name = ?;
 
+-- name: ListGuildSettings :many
+SELECT * FROM guild_settings WHERE guild_id = ?;
+
 -- name: GetAllGuilds :many
 SELECT DISTINCT guild_id FROM guild_settings;
 
@@ -12,3 +15,32 @@ INSERT OR REPLACE INTO guild_settings (guild_id, name, value) VALUES (?, ?, ?);
 
 -- name: DeleteGuildSetting :exec
 DELETE FROM guild_settings WHERE guild_id = ? AND name = ?;
+
+-- name: ListChannelSettings :many
+SELECT * FROM channel_settings WHERE guild_id = ?;
+
+-- name: SetChannelSetting :exec
+INSERT OR REPLACE INTO channel_settings (guild_id, channel_id, name, value) VALUES (?, ?, ?, ?);
+
+-- name: DeleteChannelSetting :exec
+DELETE FROM channel_settings WHERE channel_id = ? AND name = ?;
+
+-- name: AddTokenUsage :exec
+INSERT INTO token_usage (guild_id, channel_id, user_id, provider, model, day, requests, prompt_tokens, completion_tokens)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (guild_id, day, channel_id, user_id, provider, model) DO UPDATE SET
+    requests = requests + excluded.requests,
+    prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+    completion_tokens = completion_tokens + excluded.completion_tokens;
+
+-- name: ListTokenUsage :many
+SELECT * FROM token_usage WHERE guild_id = ? AND day >= ?;
+
+-- name: AddTrigger :one
+INSERT INTO triggers (guild_id, kind, pattern, probability) VALUES (?, ?, ?, ?) RETURNING id;
+
+-- name: DeleteTrigger :execrows
+DELETE FROM triggers WHERE guild_id = ? AND id = ?;
+
+-- name: ListTriggers :many
+SELECT * FROM triggers WHERE guild_id = ? ORDER BY id;
@@ -0,0 +1,156 @@
+// Package ratelimit limits how often the bot answers with in-memory token
+// buckets.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// Limit describes a token bucket: it holds up to Burst tokens and earns one
+// back every Refill. The zero Limit never limits.
+type Limit struct {
+	Burst  int
+	Refill time.Duration
+}
+
+// ParseLimit parses a limit written "<burst>/<refill>", such as "3/20s", or
+// "off" for no limit.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" {
+		return Limit{}, nil
+	}
+
+	burst, refill, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q is not <burst>/<refill> or off", ErrInvalidLimit, value)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(burst))
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("%w: burst %q is not a positive integer", ErrInvalidLimit, burst)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(refill))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w: refill %q is not a positive duration", ErrInvalidLimit, refill)
+	}
+	return Limit{Burst: n, Refill: d}, nil
+}
+
+func (l Limit) String() string {
+	if l.Burst == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%v/%v", l.Burst, l.Refill)
+}
+
+// Request asks for a token from the bucket of Key, created with Limit.
+type Request struct {
+	Key   string
+	Limit Limit
+}
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update.
+func (b *bucket) refill(now time.Time) {
+	b.tokens += float64(now.Sub(b.updated)) / float64(b.limit.Refill)
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.updated = now
+}
+
+// pruneInterval is how often buckets back to full, which behave like new
+// ones, are dropped.
+const pruneInterval = time.Minute
+
+// Limiter holds token buckets by key. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from each requested bucket if all of them have one,
+// so a rejected request does not drain the others. Otherwise it returns how
+// long to wait until they do.
+func (l *Limiter) Allow(requests ...Request) (bool, time.Duration) {
+	return l.take(requests, true)
+}
+
+// Check reports what Allow would, without taking any token.
+func (l *Limiter) Check(requests ...Request) (bool, time.Duration) {
+	return l.take(requests, false)
+}
+
+func (l *Limiter) take(requests []Request, consume bool) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(requests))
+	for _, req := range requests {
+		if req.Limit.Burst <= 0 {
+			continue
+		}
+		b, ok := l.buckets[req.Key]
+		if !ok {
+			b = &bucket{limit: req.Limit, tokens: float64(req.Limit.Burst), updated: now}
+			l.buckets[req.Key] = b
+		}
+		b.limit = req.Limit
+		b.refill(now)
+		if b.tokens < 1 {
+			if w := time.Duration((1 - b.tokens) * float64(b.limit.Refill)); w > wait {
+				wait = w
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	if !consume {
+		return true, 0
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < pruneInterval {
+		return
+	}
+	l.pruned = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
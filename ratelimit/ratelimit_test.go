@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Limit
+		err   bool
+	}{
+		{"3/20s", Limit{Burst: 3, Refill: 20 * time.Second}, false},
+		{" 1 / 1m ", Limit{Burst: 1, Refill: time.Minute}, false},
+		{"off", Limit{}, false},
+		{"3", Limit{}, true},
+		{"0/1s", Limit{}, true},
+		{"2/soon", Limit{}, true},
+		{"2/-1s", Limit{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v", tt.value, got, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseLimit(%q) error %v is not ErrInvalidLimit", tt.value, err)
+		}
+	}
+}
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Now()
+	l := New()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	l, now := newTestLimiter()
+	req := Request{Key: "user", Limit: Limit{Burst: 2, Refill: 10 * time.Second}}
+
+	for n := 0; n < 2; n++ {
+		if ok, _ := l.Allow(req); !ok {
+			t.Fatalf("request %v rejected within the burst", n)
+		}
+	}
+	ok, wait := l.Allow(req)
+	if ok || wait != 10*time.Second {
+		t.Fatalf("got %v, %v after the burst, want a 10s wait", ok, wait)
+	}
+
+	*now = now.Add(4 * time.Second)
+	if _, wait := l.Allow(req); wait != 6*time.Second {
+		t.Errorf("wait is %v after 4s, want 6s", wait)
+	}
+
+	*now = now.Add(6 * time.Second)
+	if ok, _ := l.Allow(req); !ok {
+		t.Error("rejected once a token was earned back")
+	}
+}
+
+func TestLimiterAllOrNothing(t *testing.T) {
+	l, _ := newTestLimiter()
+	user := Request{Key: "user", Limit: Limit{Burst: 1, Refill: time.Hour}}
+	channel := Request{Key: "channel", Limit: Limit{Burst: 2, Refill: time.Hour}}
+
+	if ok, _ := l.Allow(user, channel); !ok {
+		t.Fatal("first request rejected")
+	}
+	if ok, _ := l.Allow(user, channel); ok {
+		t.Fatal("allowed past the user limit")
+	}
+	if ok, _ := l.Allow(channel); !ok {
+		t.Fatal("a rejected request drained the channel bucket")
+	}
+}
+
+func TestLimiterCheck(t *testing.T) {
+	l, _ := newTestLimiter()
+	req := Request{Key: "channel", Limit: Limit{Burst: 1, Refill: 10 * time.Second}}
+
+	for n := 0; n < 3; n++ {
+		if ok, _ := l.Check(req); !ok {
+			t.Fatal("Check rejected a full bucket")
+		}
+	}
+	if ok, _ := l.Allow(req); !ok {
+		t.Fatal("Check took a token")
+	}
+	if ok, wait := l.Check(req); ok || wait != 10*time.Second {
+		t.Errorf("got %v, %v for an empty bucket, want a 10s wait", ok, wait)
+	}
+}
+
+func TestLimiterOff(t *testing.T) {
+	l, _ := newTestLimiter()
+	for n := 0; n < 100; n++ {
+		if ok, _ := l.Allow(Request{Key: "user"}); !ok {
+			t.Fatal("the zero limit rejected a request")
+		}
+	}
+}
+
+func TestLimiterPrunesFullBuckets(t *testing.T) {
+	l, now := newTestLimiter()
+	l.Allow(Request{Key: "user", Limit: Limit{Burst: 1, Refill: time.Second}})
+
+	*now = now.Add(2 * pruneInterval)
+	l.Allow()
+	if len(l.buckets) != 0 {
+		t.Errorf("%v buckets left", len(l.buckets))
+	}
+}
@@ -0,0 +1,155 @@
+package settings
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cache keeps every setting of a guild, channel overrides included, in
+// memory once it has been read. Writes made through Settings update it
+// directly; entries older than ttl are reloaded so that changes made to the
+// database by other means show up.
+//
+// Each write bumps the generation of its guild, so that values loaded
+// before it are not cached over it.
+type cache struct {
+	ttl         time.Duration
+	now         func() time.Time
+	mu          sync.Mutex
+	guilds      map[string]*cachedGuild
+	generations map[string]uint64
+}
+
+type cachedGuild struct {
+	values *guildValues
+	loaded time.Time
+}
+
+// guildValues is never modified once handed out by the cache; updates
+// replace it with a copy.
+type guildValues struct {
+	guild    map[string]string
+	channels map[string]map[string]string
+}
+
+func (v *guildValues) clone() *guildValues {
+	c := &guildValues{
+		guild:    make(map[string]string, len(v.guild)),
+		channels: make(map[string]map[string]string, len(v.channels)),
+	}
+	for k, value := range v.guild {
+		c.guild[k] = value
+	}
+	for channelID, values := range v.channels {
+		c.channels[channelID] = make(map[string]string, len(values))
+		for k, value := range values {
+			c.channels[channelID][k] = value
+		}
+	}
+	return c
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl:         ttl,
+		now:         time.Now,
+		guilds:      map[string]*cachedGuild{},
+		generations: map[string]uint64{},
+	}
+}
+
+// get returns the cached values of a guild, or the generation to pass to
+// store once they are loaded.
+func (c *cache) get(guildID string) (*guildValues, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	guild, ok := c.guilds[guildID]
+	if !ok || c.now().Sub(guild.loaded) > c.ttl {
+		return nil, c.generations[guildID], false
+	}
+	return guild.values, 0, true
+}
+
+// store caches values loaded at generation, unless the guild was written
+// since.
+func (c *cache) store(guildID string, generation uint64, values *guildValues) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[guildID] != generation {
+		return
+	}
+	c.guilds[guildID] = &cachedGuild{values: values, loaded: c.now()}
+}
+
+// set and remove change a guild value, or a channel override when channelID
+// is not empty.
+func (c *cache) set(guildID, channelID, name, value string) {
+	c.update(guildID, func(values *guildValues) {
+		if channelID == "" {
+			values.guild[name] = value
+			return
+		}
+		if values.channels[channelID] == nil {
+			values.channels[channelID] = map[string]string{}
+		}
+		values.channels[channelID][name] = value
+	})
+}
+
+func (c *cache) remove(guildID, channelID, name string) {
+	c.update(guildID, func(values *guildValues) {
+		if channelID == "" {
+			delete(values.guild, name)
+			return
+		}
+		delete(values.channels[channelID], name)
+	})
+}
+
+func (c *cache) update(guildID string, change func(*guildValues)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[guildID]++
+	guild, ok := c.guilds[guildID]
+	if !ok {
+		return
+	}
+	values := guild.values.clone()
+	change(values)
+	guild.values = values
+}
+
+func (c *cache) invalidate(guildID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[guildID]++
+	delete(c.guilds, guildID)
+}
+
+// values returns all the stored settings of a guild, from the cache when
+// possible.
+func (s *Settings) values(ctx context.Context, guildID string) (*guildValues, error) {
+	values, generation, ok := s.cache.get(guildID)
+	if ok {
+		return values, nil
+	}
+
+	guild, err := s.store.GuildSettings(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := s.store.ChannelSettings(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	values = &guildValues{guild: guild, channels: channels}
+	s.cache.store(guildID, generation, values)
+	return values, nil
+}
+
+// Invalidate drops the cached settings of a guild.
+func (s *Settings) Invalidate(guildID string) {
+	s.cache.invalidate(guildID)
+}
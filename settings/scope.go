@@ -0,0 +1,25 @@
+package settings
+
+// Scope is where a setting is read or written. Reads go through ChannelIDs
+// in order, most specific first (e.g. a channel then its category), before
+// falling back to the guild. Writes target ChannelIDs[0], or the guild when
+// there are no channels.
+type Scope struct {
+	GuildID    string
+	ChannelIDs []string
+}
+
+func Guild(guildID string) Scope {
+	return Scope{GuildID: guildID}
+}
+
+func Channel(guildID string, channelIDs ...string) Scope {
+	return Scope{GuildID: guildID, ChannelIDs: channelIDs}
+}
+
+func (s Scope) target() (string, bool) {
+	if len(s.ChannelIDs) == 0 {
+		return "", false
+	}
+	return s.ChannelIDs[0], true
+}
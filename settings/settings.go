@@ -0,0 +1,322 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"polynux/disgoroq/store"
+)
+
+type Kind int
+
+const (
+	Float Kind = iota
+	Int
+	Bool
+	String
+	Choice
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Float:
+		return "number"
+	case Int:
+		return "integer"
+	case Bool:
+		return "on/off"
+	case Choice:
+		return "choice"
+	default:
+		return "text"
+	}
+}
+
+var (
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidValue   = errors.New("invalid value")
+)
+
+// Setting describes a guild setting. Values are stored as strings in
+// guild_settings; Kind, the bounds and Choices decide which strings are
+// accepted.
+type Setting struct {
+	Name        string
+	Description string
+	Kind        Kind
+	// Default is the stored representation used when the guild has no
+	// valid value.
+	Default string
+	// Min and Max bound Float and Int values, and the length of String
+	// values. They are ignored when Max is not greater than Min.
+	Min, Max float64
+	Choices  []string
+	// Permission is the member permission needed to change the setting,
+	// zero meaning anyone can.
+	Permission int64
+	// Validate runs after the generic checks, for constraints that depend
+	// on other settings.
+	Validate func(ctx context.Context, scope Scope, value string) error
+}
+
+func (s *Setting) bounded() bool {
+	return s.Max > s.Min
+}
+
+// Normalize checks value against the setting and returns the form it is
+// stored in.
+func (s *Setting) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch s.Kind {
+	case Float:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v must be a number", ErrInvalidValue, s.Name)
+		}
+		if s.bounded() && (f < s.Min || f > s.Max) {
+			return "", fmt.Errorf("%w: %v must be between %v and %v", ErrInvalidValue, s.Name, s.Min, s.Max)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v must be an integer", ErrInvalidValue, s.Name)
+		}
+		if s.bounded() && (float64(n) < s.Min || float64(n) > s.Max) {
+			return "", fmt.Errorf("%w: %v must be between %v and %v", ErrInvalidValue, s.Name, s.Min, s.Max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case Bool:
+		b, ok := parseBool(value)
+		if !ok {
+			return "", fmt.Errorf("%w: %v must be on or off", ErrInvalidValue, s.Name)
+		}
+		return formatBool(b), nil
+	case Choice:
+		for _, c := range s.Choices {
+			if strings.EqualFold(c, value) {
+				return c, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %v must be one of %v", ErrInvalidValue, s.Name, strings.Join(s.Choices, ", "))
+	default:
+		length := float64(utf8.RuneCountInString(value))
+		if s.bounded() && (length < s.Min || length > s.Max) {
+			return "", fmt.Errorf("%w: %v must be between %v and %v characters", ErrInvalidValue, s.Name, s.Min, s.Max)
+		}
+		return value, nil
+	}
+}
+
+// Range describes the accepted values in a human readable way.
+func (s *Setting) Range() string {
+	switch {
+	case s.Kind == Bool:
+		return "on, off"
+	case s.Kind == Choice:
+		return strings.Join(s.Choices, ", ")
+	case s.Kind == String && s.bounded():
+		return fmt.Sprintf("%v-%v characters", s.Min, s.Max)
+	case s.bounded():
+		return fmt.Sprintf("%v-%v", s.Min, s.Max)
+	default:
+		return "any " + s.Kind.String()
+	}
+}
+
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, true
+	case "off", "false", "no", "0":
+		return false, true
+	}
+	return false, false
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// Value is a setting value as stored, with typed accessors.
+type Value struct {
+	Setting *Setting
+	Raw     string
+	// Source is where the value comes from: a channel ID for an override,
+	// GuildSource for a guild-wide value and empty for the default.
+	Source string
+}
+
+// GuildSource is the Source of values set for the whole guild.
+const GuildSource = "guild"
+
+func (v Value) IsDefault() bool {
+	return v.Source == ""
+}
+
+func (v Value) String() string {
+	return v.Raw
+}
+
+func (v Value) Float() float64 {
+	f, _ := strconv.ParseFloat(v.Raw, 64)
+	return f
+}
+
+func (v Value) Int() int {
+	n, _ := strconv.Atoi(v.Raw)
+	return n
+}
+
+func (v Value) Bool() bool {
+	b, _ := parseBool(v.Raw)
+	return b
+}
+
+type Registry struct {
+	settings map[string]*Setting
+}
+
+func NewRegistry(settings ...Setting) *Registry {
+	r := &Registry{settings: map[string]*Setting{}}
+	for i := range settings {
+		r.settings[settings[i].Name] = &settings[i]
+	}
+	return r
+}
+
+func (r *Registry) Lookup(name string) (*Setting, bool) {
+	s, ok := r.settings[name]
+	return s, ok
+}
+
+// All returns the settings sorted by name.
+func (r *Registry) All() []*Setting {
+	all := make([]*Setting, 0, len(r.settings))
+	for _, s := range r.settings {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Settings reads and writes registered settings, caching each guild's
+// values for cacheTTL.
+type Settings struct {
+	Registry *Registry
+	store    store.Store
+	cache    *cache
+}
+
+func New(registry *Registry, st store.Store, cacheTTL time.Duration) *Settings {
+	return &Settings{
+		Registry: registry,
+		store:    st,
+		cache:    newCache(cacheTTL),
+	}
+}
+
+// Get resolves a setting for scope: the first channel of the scope
+// overriding it wins, then the guild value, then the default. Stored values
+// that are no longer valid are skipped.
+func (s *Settings) Get(ctx context.Context, scope Scope, name string) Value {
+	setting, ok := s.Registry.Lookup(name)
+	if !ok {
+		return Value{Setting: &Setting{Name: name}}
+	}
+	values, err := s.values(ctx, scope.GuildID)
+	if err == nil {
+		for _, channelID := range scope.ChannelIDs {
+			if raw, ok := values.channels[channelID][name]; ok {
+				if value, err := setting.Normalize(raw); err == nil {
+					return Value{Setting: setting, Raw: value, Source: channelID}
+				}
+			}
+		}
+		if raw, ok := values.guild[name]; ok {
+			if value, err := setting.Normalize(raw); err == nil {
+				return Value{Setting: setting, Raw: value, Source: GuildSource}
+			}
+		}
+	}
+	return Value{Setting: setting, Raw: setting.Default}
+}
+
+// Set validates and stores a setting at the most specific level of scope,
+// returning the stored value.
+func (s *Settings) Set(ctx context.Context, scope Scope, name, value string) (Value, error) {
+	setting, ok := s.Registry.Lookup(name)
+	if !ok {
+		return Value{}, fmt.Errorf("%w: %v", ErrUnknownSetting, name)
+	}
+	value, err := setting.Normalize(value)
+	if err != nil {
+		return Value{}, err
+	}
+	if setting.Validate != nil {
+		if err := setting.Validate(ctx, scope, value); err != nil {
+			return Value{}, err
+		}
+	}
+
+	channelID, isChannel := scope.target()
+	if isChannel {
+		err = s.store.SetChannelSetting(ctx, scope.GuildID, channelID, name, value)
+	} else {
+		err = s.store.SetGuildSetting(ctx, scope.GuildID, name, value)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	s.cache.set(scope.GuildID, channelID, name, value)
+
+	source := GuildSource
+	if isChannel {
+		source = channelID
+	}
+	return Value{Setting: setting, Raw: value, Source: source}, nil
+}
+
+func (s *Settings) SetFloat(ctx context.Context, scope Scope, name string, value float64) (Value, error) {
+	return s.Set(ctx, scope, name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (s *Settings) SetInt(ctx context.Context, scope Scope, name string, value int64) (Value, error) {
+	return s.Set(ctx, scope, name, strconv.FormatInt(value, 10))
+}
+
+func (s *Settings) SetBool(ctx context.Context, scope Scope, name string, value bool) (Value, error) {
+	return s.Set(ctx, scope, name, formatBool(value))
+}
+
+// Reset removes the value stored at the most specific level of scope so the
+// next level applies again.
+func (s *Settings) Reset(ctx context.Context, scope Scope, name string) error {
+	if _, ok := s.Registry.Lookup(name); !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownSetting, name)
+	}
+
+	var err error
+	channelID, isChannel := scope.target()
+	if isChannel {
+		err = s.store.DeleteChannelSetting(ctx, scope.GuildID, channelID, name)
+	} else {
+		err = s.store.DeleteGuildSetting(ctx, scope.GuildID, name)
+	}
+	if err != nil {
+		return err
+	}
+	s.cache.remove(scope.GuildID, channelID, name)
+	return nil
+}
@@ -0,0 +1,259 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/store"
+)
+
+func testRegistry() *Registry {
+	return NewRegistry(
+		Setting{Name: "threshold", Kind: Float, Default: "0.1", Min: 0, Max: 1},
+		Setting{Name: "max_tokens", Kind: Int, Default: "500", Min: 1, Max: 4096},
+		Setting{Name: "enabled", Kind: Bool, Default: "off"},
+		Setting{Name: "mode", Kind: Choice, Default: "random", Choices: []string{"random", "relevance"}},
+		Setting{Name: "prompt", Kind: String, Default: "", Min: 0, Max: 5},
+		Setting{Name: "name", Kind: String, Default: "bot"},
+	)
+}
+
+func newTestSettings(t *testing.T) (*Settings, *store.Memory) {
+	t.Helper()
+	st := store.NewMemory()
+	return New(testRegistry(), st, time.Minute), st
+}
+
+func TestNormalize(t *testing.T) {
+	r := testRegistry()
+	tests := []struct {
+		name, value, want string
+		err               bool
+	}{
+		{"threshold", " 0.50 ", "0.5", false},
+		{"threshold", "1", "1", false},
+		{"threshold", "1.5", "", true},
+		{"threshold", "-0.1", "", true},
+		{"threshold", "half", "", true},
+		{"max_tokens", "4096", "4096", false},
+		{"max_tokens", "0", "", true},
+		{"max_tokens", "1.5", "", true},
+		{"enabled", "Yes", "on", false},
+		{"enabled", "0", "off", false},
+		{"enabled", "maybe", "", true},
+		{"mode", "RELEVANCE", "relevance", false},
+		{"mode", "always", "", true},
+		{"prompt", "héllo", "héllo", false},
+		{"prompt", "hello!", "", true},
+		// Max not greater than Min leaves a setting unbounded.
+		{"name", "a very long name", "a very long name", false},
+	}
+	for _, tt := range tests {
+		setting, _ := r.Lookup(tt.name)
+		got, err := setting.Normalize(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%v: Normalize(%q) = %q, %v", tt.name, tt.value, got, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("%v: Normalize(%q) returned %v, not ErrInvalidValue", tt.name, tt.value, err)
+		}
+	}
+}
+
+func TestGetDefaults(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSettings(t)
+
+	v := s.Get(ctx, Guild("g"), "max_tokens")
+	if v.Int() != 500 || !v.IsDefault() {
+		t.Errorf("got %+v, want the default", v)
+	}
+	if v := s.Get(ctx, Guild("g"), "unknown"); v.Raw != "" || v.Setting.Name != "unknown" {
+		t.Errorf("got %+v for an unknown setting", v)
+	}
+}
+
+func TestGetSkipsInvalidValues(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSettings(t)
+
+	// Stored before the bounds changed, or by hand.
+	st.SetGuildSetting(ctx, "g", "threshold", "2")
+	st.SetGuildSetting(ctx, "g", "enabled", "on")
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.1 || !v.IsDefault() {
+		t.Errorf("got %+v, want the default", v)
+	}
+	if v := s.Get(ctx, Guild("g"), "enabled"); !v.Bool() || v.Source != GuildSource {
+		t.Errorf("got %+v, want the guild value", v)
+	}
+}
+
+func TestSetRejectsInvalidValues(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSettings(t)
+
+	if _, err := s.Set(ctx, Guild("g"), "threshold", "2"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("got %v, want ErrInvalidValue", err)
+	}
+	if _, err := s.Set(ctx, Guild("g"), "unknown", "1"); !errors.Is(err, ErrUnknownSetting) {
+		t.Errorf("got %v, want ErrUnknownSetting", err)
+	}
+	if _, err := st.GetGuildSetting(ctx, "g", "threshold"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("stored an invalid value: %v", err)
+	}
+}
+
+func TestSetAndResetUpdateTheCache(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSettings(t)
+
+	s.Get(ctx, Guild("g"), "max_tokens")
+	// Changed behind the cache: not seen until it is reloaded.
+	st.SetGuildSetting(ctx, "g", "threshold", "0.9")
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.1 {
+		t.Fatalf("got %v, want the cached default", v.Raw)
+	}
+
+	if _, err := s.Set(ctx, Guild("g"), "max_tokens", "100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Set(ctx, Channel("g", "c"), "max_tokens", "200"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, Guild("g"), "max_tokens"); v.Int() != 100 || v.Source != GuildSource {
+		t.Errorf("got %+v after Set", v)
+	}
+	if v := s.Get(ctx, Channel("g", "c"), "max_tokens"); v.Int() != 200 || v.Source != "c" {
+		t.Errorf("got %+v after Set on a channel", v)
+	}
+
+	if err := s.Reset(ctx, Channel("g", "c"), "max_tokens"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, Channel("g", "c"), "max_tokens"); v.Int() != 100 || v.Source != GuildSource {
+		t.Errorf("got %+v after resetting the channel", v)
+	}
+	if err := s.Reset(ctx, Guild("g"), "max_tokens"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, Channel("g", "c"), "max_tokens"); !v.IsDefault() {
+		t.Errorf("got %+v after resetting the guild", v)
+	}
+	if _, err := st.GetGuildSetting(ctx, "g", "max_tokens"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Reset left the value stored: %v", err)
+	}
+}
+
+func TestCacheReloadsAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSettings(t)
+	now := time.Now()
+	s.cache.now = func() time.Time { return now }
+
+	s.Get(ctx, Guild("g"), "threshold")
+	st.SetGuildSetting(ctx, "g", "threshold", "0.9")
+
+	now = now.Add(time.Minute)
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.1 {
+		t.Errorf("got %v, reloaded before the TTL", v.Raw)
+	}
+	now = now.Add(time.Second)
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.9 {
+		t.Errorf("got %v, not reloaded after the TTL", v.Raw)
+	}
+
+	st.SetGuildSetting(ctx, "g", "threshold", "0.5")
+	s.Invalidate("g")
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.5 {
+		t.Errorf("got %v, not reloaded after Invalidate", v.Raw)
+	}
+}
+
+// racingStore runs write once, right after reading the channel settings of
+// a guild, as if it happened while Settings was loading them.
+type racingStore struct {
+	*store.Memory
+	write func()
+}
+
+func (r *racingStore) ChannelSettings(ctx context.Context, guildID string) (map[string]map[string]string, error) {
+	values, err := r.Memory.ChannelSettings(ctx, guildID)
+	if write := r.write; write != nil {
+		r.write = nil
+		write()
+	}
+	return values, err
+}
+
+func TestCacheSkipsLoadsRacingWrites(t *testing.T) {
+	ctx := context.Background()
+	st := &racingStore{Memory: store.NewMemory()}
+	s := New(testRegistry(), st, time.Minute)
+
+	st.write = func() {
+		if _, err := s.Set(ctx, Guild("g"), "threshold", "0.9"); err != nil {
+			t.Error(err)
+		}
+	}
+	s.Get(ctx, Guild("g"), "threshold")
+	// The values loaded before Set are not cached over it.
+	if v := s.Get(ctx, Guild("g"), "threshold"); v.Float() != 0.9 {
+		t.Errorf("got %v, want the value set while loading", v.Raw)
+	}
+
+	st.write = func() { s.Reset(ctx, Guild("g"), "threshold") }
+	s.Invalidate("g")
+	s.Get(ctx, Guild("g"), "threshold")
+	if v := s.Get(ctx, Guild("g"), "threshold"); !v.IsDefault() {
+		t.Errorf("got %v, want the default after a reset while loading", v.Raw)
+	}
+}
+
+func TestGetLookupOrder(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSettings(t)
+
+	// A channel, then its category, then the guild.
+	scope := Channel("g", "channel", "category")
+	if _, err := s.Set(ctx, Guild("g"), "max_tokens", "100"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, scope, "max_tokens"); v.Int() != 100 || v.Source != GuildSource {
+		t.Errorf("got %+v, want the guild value", v)
+	}
+
+	if _, err := s.Set(ctx, Channel("g", "category"), "max_tokens", "200"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, scope, "max_tokens"); v.Int() != 200 || v.Source != "category" {
+		t.Errorf("got %+v, want the category override", v)
+	}
+
+	if _, err := s.Set(ctx, scope, "max_tokens", "300"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get(ctx, scope, "max_tokens"); v.Int() != 300 || v.Source != "channel" {
+		t.Errorf("got %+v, want the channel override", v)
+	}
+
+	// Overrides of other channels do not apply.
+	if v := s.Get(ctx, Channel("g", "other"), "max_tokens"); v.Int() != 100 {
+		t.Errorf("got %+v for another channel", v)
+	}
+	if v := s.Get(ctx, Guild("g"), "max_tokens"); v.Int() != 100 {
+		t.Errorf("got %+v for the guild", v)
+	}
+}
+
+func TestGetSkipsInvalidOverrides(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSettings(t)
+
+	st.SetChannelSetting(ctx, "g", "channel", "threshold", "2")
+	st.SetChannelSetting(ctx, "g", "category", "threshold", "0.3")
+	if v := s.Get(ctx, Channel("g", "channel", "category"), "threshold"); v.Float() != 0.3 || v.Source != "category" {
+		t.Errorf("got %+v, want the category override", v)
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/tursodatabase/go-libsql"
+
+	"polynux/disgoroq/db"
+)
+
+// LibSQL stores settings in a libsql (SQLite) database through the sqlc
+// queries.
+type LibSQL struct {
+	db        *sql.DB
+	q         *db.Queries
+	connector *libsql.Connector
+}
+
+func NewLibSQL(conn *sql.DB) *LibSQL {
+	return &LibSQL{db: conn, q: db.New(conn)}
+}
+
+// NewLibSQLReplica returns a store over an embedded replica opened with
+// connector, which Flush syncs with the remote database.
+func NewLibSQLReplica(conn *sql.DB, connector *libsql.Connector) *LibSQL {
+	return &LibSQL{db: conn, q: db.New(conn), connector: connector}
+}
+
+func (s *LibSQL) GetGuildSetting(ctx context.Context, guildID, name string) (string, error) {
+	value, err := s.q.GetGuildSetting(ctx, db.GetGuildSettingParams{
+		GuildID: guildID,
+		Name:    name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *LibSQL) GuildSettings(ctx context.Context, guildID string) (map[string]string, error) {
+	rows, err := s.q.ListGuildSettings(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row.Name] = row.Value
+	}
+	return values, nil
+}
+
+func (s *LibSQL) SetGuildSetting(ctx context.Context, guildID, name, value string) error {
+	return s.q.SetGuildSetting(ctx, db.SetGuildSettingParams{
+		GuildID: guildID,
+		Name:    name,
+		Value:   value,
+	})
+}
+
+func (s *LibSQL) DeleteGuildSetting(ctx context.Context, guildID, name string) error {
+	return s.q.DeleteGuildSetting(ctx, db.DeleteGuildSettingParams{
+		GuildID: guildID,
+		Name:    name,
+	})
+}
+
+func (s *LibSQL) ChannelSettings(ctx context.Context, guildID string) (map[string]map[string]string, error) {
+	rows, err := s.q.ListChannelSettings(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]map[string]string{}
+	for _, row := range rows {
+		if values[row.ChannelID] == nil {
+			values[row.ChannelID] = map[string]string{}
+		}
+		values[row.ChannelID][row.Name] = row.Value
+	}
+	return values, nil
+}
+
+func (s *LibSQL) SetChannelSetting(ctx context.Context, guildID, channelID, name, value string) error {
+	return s.q.SetChannelSetting(ctx, db.SetChannelSettingParams{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Name:      name,
+		Value:     value,
+	})
+}
+
+func (s *LibSQL) DeleteChannelSetting(ctx context.Context, guildID, channelID, name string) error {
+	return s.q.DeleteChannelSetting(ctx, db.DeleteChannelSettingParams{
+		ChannelID: channelID,
+		Name:      name,
+	})
+}
+
+func (s *LibSQL) AddUsage(ctx context.Context, u Usage) error {
+	return s.q.AddTokenUsage(ctx, db.AddTokenUsageParams{
+		GuildID:          u.GuildID,
+		ChannelID:        u.ChannelID,
+		UserID:           u.UserID,
+		Provider:         u.Provider,
+		Model:            u.Model,
+		Day:              u.Day,
+		Requests:         int64(u.Requests),
+		PromptTokens:     int64(u.PromptTokens),
+		CompletionTokens: int64(u.CompletionTokens),
+	})
+}
+
+func (s *LibSQL) ListUsage(ctx context.Context, guildID, since string) ([]Usage, error) {
+	rows, err := s.q.ListTokenUsage(ctx, db.ListTokenUsageParams{
+		GuildID: guildID,
+		Day:     since,
+	})
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]Usage, 0, len(rows))
+	for _, row := range rows {
+		usage = append(usage, Usage{
+			GuildID:          row.GuildID,
+			ChannelID:        row.ChannelID,
+			UserID:           row.UserID,
+			Provider:         row.Provider,
+			Model:            row.Model,
+			Day:              libsqlDay(row.Day),
+			Requests:         int(row.Requests),
+			PromptTokens:     int(row.PromptTokens),
+			CompletionTokens: int(row.CompletionTokens),
+		})
+	}
+	return usage, nil
+}
+
+func (s *LibSQL) Triggers(ctx context.Context, guildID string) ([]Trigger, error) {
+	rows, err := s.q.ListTriggers(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]Trigger, 0, len(rows))
+	for _, row := range rows {
+		triggers = append(triggers, Trigger(row))
+	}
+	return triggers, nil
+}
+
+func (s *LibSQL) AddTrigger(ctx context.Context, t Trigger) (int64, error) {
+	return s.q.AddTrigger(ctx, db.AddTriggerParams{
+		GuildID:     t.GuildID,
+		Kind:        t.Kind,
+		Pattern:     t.Pattern,
+		Probability: t.Probability,
+	})
+}
+
+func (s *LibSQL) DeleteTrigger(ctx context.Context, guildID string, id int64) error {
+	deleted, err := s.q.DeleteTrigger(ctx, db.DeleteTriggerParams{
+		GuildID: guildID,
+		ID:      id,
+	})
+	if err == nil && deleted == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// libsqlDay undoes libsql reading a YYYY-MM-DD text column as a time,
+// which it then scans as an RFC 3339 timestamp.
+func libsqlDay(day string) string {
+	if len(day) > len("2006-01-02") {
+		return day[:len("2006-01-02")]
+	}
+	return day
+}
+
+func (s *LibSQL) Flush(ctx context.Context) error {
+	if s.connector == nil {
+		return nil
+	}
+	_, err := s.connector.Sync()
+	return err
+}
+
+func (s *LibSQL) Close() error {
+	return s.db.Close()
+}
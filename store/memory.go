@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps settings, usage and triggers in memory only. It is meant for tests and trying
+// the bot out.
+type Memory struct {
+	mu       sync.Mutex
+	guilds   map[string]map[string]string
+	channels map[string]map[string]map[string]string
+	usage    []Usage
+	triggers []Trigger
+	nextID   int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		guilds:   map[string]map[string]string{},
+		channels: map[string]map[string]map[string]string{},
+	}
+}
+
+func (m *Memory) GetGuildSetting(ctx context.Context, guildID, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.guilds[guildID][name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *Memory) GuildSettings(ctx context.Context, guildID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make(map[string]string, len(m.guilds[guildID]))
+	for name, value := range m.guilds[guildID] {
+		values[name] = value
+	}
+	return values, nil
+}
+
+func (m *Memory) SetGuildSetting(ctx context.Context, guildID, name, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.guilds[guildID] == nil {
+		m.guilds[guildID] = map[string]string{}
+	}
+	m.guilds[guildID][name] = value
+	return nil
+}
+
+func (m *Memory) DeleteGuildSetting(ctx context.Context, guildID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.guilds[guildID], name)
+	return nil
+}
+
+func (m *Memory) ChannelSettings(ctx context.Context, guildID string) (map[string]map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := map[string]map[string]string{}
+	for channelID, settings := range m.channels[guildID] {
+		values[channelID] = make(map[string]string, len(settings))
+		for name, value := range settings {
+			values[channelID][name] = value
+		}
+	}
+	return values, nil
+}
+
+func (m *Memory) SetChannelSetting(ctx context.Context, guildID, channelID, name, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.channels[guildID] == nil {
+		m.channels[guildID] = map[string]map[string]string{}
+	}
+	if m.channels[guildID][channelID] == nil {
+		m.channels[guildID][channelID] = map[string]string{}
+	}
+	m.channels[guildID][channelID][name] = value
+	return nil
+}
+
+func (m *Memory) DeleteChannelSetting(ctx context.Context, guildID, channelID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.channels[guildID][channelID], name)
+	return nil
+}
+
+func (m *Memory) AddUsage(ctx context.Context, u Usage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, row := range m.usage {
+		if row.GuildID == u.GuildID && row.ChannelID == u.ChannelID && row.UserID == u.UserID &&
+			row.Provider == u.Provider && row.Model == u.Model && row.Day == u.Day {
+			m.usage[i].Requests += u.Requests
+			m.usage[i].PromptTokens += u.PromptTokens
+			m.usage[i].CompletionTokens += u.CompletionTokens
+			return nil
+		}
+	}
+	m.usage = append(m.usage, u)
+	return nil
+}
+
+func (m *Memory) ListUsage(ctx context.Context, guildID, since string) ([]Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var usage []Usage
+	for _, row := range m.usage {
+		if row.GuildID == guildID && row.Day >= since {
+			usage = append(usage, row)
+		}
+	}
+	return usage, nil
+}
+
+func (m *Memory) Triggers(ctx context.Context, guildID string) ([]Trigger, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var triggers []Trigger
+	for _, t := range m.triggers {
+		if t.GuildID == guildID {
+			triggers = append(triggers, t)
+		}
+	}
+	return triggers, nil
+}
+
+func (m *Memory) AddTrigger(ctx context.Context, t Trigger) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	t.ID = m.nextID
+	m.triggers = append(m.triggers, t)
+	return t.ID, nil
+}
+
+func (m *Memory) DeleteTrigger(ctx context.Context, guildID string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.triggers {
+		if t.GuildID == guildID && t.ID == id {
+			m.triggers = append(m.triggers[:i], m.triggers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
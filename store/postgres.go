@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	_ "github.com/lib/pq"
+
+	"polynux/disgoroq/migrations"
+)
+
+// Postgres stores settings in a PostgreSQL database. The schema mirrors the
+// SQLite one, see migrations/postgres.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres connects to url and applies the pending migrations.
+func NewPostgres(ctx context.Context, url string) (*Postgres, []migrations.Migration, error) {
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	applied, err := migrations.Run(ctx, conn, migrations.Postgres)
+	if err != nil {
+		conn.Close()
+		return nil, applied, err
+	}
+	return &Postgres{db: conn}, applied, nil
+}
+
+func (p *Postgres) GetGuildSetting(ctx context.Context, guildID, name string) (string, error) {
+	var value string
+	err := p.db.QueryRowContext(ctx,
+		"SELECT value FROM guild_settings WHERE guild_id = $1 AND name = $2",
+		guildID, name,
+	).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (p *Postgres) GuildSettings(ctx context.Context, guildID string) (map[string]string, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT name, value FROM guild_settings WHERE guild_id = $1",
+		guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, rows.Err()
+}
+
+func (p *Postgres) SetGuildSetting(ctx context.Context, guildID, name, value string) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO guild_settings (guild_id, name, value) VALUES ($1, $2, $3)
+		ON CONFLICT (guild_id, name) DO UPDATE SET value = EXCLUDED.value`,
+		guildID, name, value,
+	)
+	return err
+}
+
+func (p *Postgres) DeleteGuildSetting(ctx context.Context, guildID, name string) error {
+	_, err := p.db.ExecContext(ctx,
+		"DELETE FROM guild_settings WHERE guild_id = $1 AND name = $2",
+		guildID, name,
+	)
+	return err
+}
+
+func (p *Postgres) ChannelSettings(ctx context.Context, guildID string) (map[string]map[string]string, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT channel_id, name, value FROM channel_settings WHERE guild_id = $1",
+		guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[string]map[string]string{}
+	for rows.Next() {
+		var channelID, name, value string
+		if err := rows.Scan(&channelID, &name, &value); err != nil {
+			return nil, err
+		}
+		if values[channelID] == nil {
+			values[channelID] = map[string]string{}
+		}
+		values[channelID][name] = value
+	}
+	return values, rows.Err()
+}
+
+func (p *Postgres) SetChannelSetting(ctx context.Context, guildID, channelID, name, value string) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO channel_settings (guild_id, channel_id, name, value) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (channel_id, name) DO UPDATE SET value = EXCLUDED.value, guild_id = EXCLUDED.guild_id`,
+		guildID, channelID, name, value,
+	)
+	return err
+}
+
+func (p *Postgres) DeleteChannelSetting(ctx context.Context, guildID, channelID, name string) error {
+	_, err := p.db.ExecContext(ctx,
+		"DELETE FROM channel_settings WHERE channel_id = $1 AND name = $2",
+		channelID, name,
+	)
+	return err
+}
+
+func (p *Postgres) AddUsage(ctx context.Context, u Usage) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO token_usage (guild_id, channel_id, user_id, provider, model, day, requests, prompt_tokens, completion_tokens)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (guild_id, day, channel_id, user_id, provider, model) DO UPDATE SET
+			requests = token_usage.requests + EXCLUDED.requests,
+			prompt_tokens = token_usage.prompt_tokens + EXCLUDED.prompt_tokens,
+			completion_tokens = token_usage.completion_tokens + EXCLUDED.completion_tokens`,
+		u.GuildID, u.ChannelID, u.UserID, u.Provider, u.Model, u.Day, u.Requests, u.PromptTokens, u.CompletionTokens,
+	)
+	return err
+}
+
+func (p *Postgres) ListUsage(ctx context.Context, guildID, since string) ([]Usage, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT channel_id, user_id, provider, model, day, requests, prompt_tokens, completion_tokens
+		FROM token_usage WHERE guild_id = $1 AND day >= $2`,
+		guildID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []Usage
+	for rows.Next() {
+		u := Usage{GuildID: guildID}
+		if err := rows.Scan(&u.ChannelID, &u.UserID, &u.Provider, &u.Model, &u.Day, &u.Requests, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (p *Postgres) Triggers(ctx context.Context, guildID string) ([]Trigger, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT id, kind, pattern, probability FROM triggers WHERE guild_id = $1 ORDER BY id",
+		guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var triggers []Trigger
+	for rows.Next() {
+		t := Trigger{GuildID: guildID}
+		if err := rows.Scan(&t.ID, &t.Kind, &t.Pattern, &t.Probability); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, rows.Err()
+}
+
+func (p *Postgres) AddTrigger(ctx context.Context, t Trigger) (int64, error) {
+	var id int64
+	err := p.db.QueryRowContext(ctx,
+		"INSERT INTO triggers (guild_id, kind, pattern, probability) VALUES ($1, $2, $3, $4) RETURNING id",
+		t.GuildID, t.Kind, t.Pattern, t.Probability,
+	).Scan(&id)
+	return id, err
+}
+
+func (p *Postgres) DeleteTrigger(ctx context.Context, guildID string, id int64) error {
+	result, err := p.db.ExecContext(ctx,
+		"DELETE FROM triggers WHERE guild_id = $1 AND id = $2",
+		guildID, id,
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err == nil && deleted == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Usage is the tokens used by completions, summed by guild, channel, user,
+// model and day.
+type Usage struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	Provider  string
+	Model     string
+	// Day is the UTC date of the completions, as YYYY-MM-DD.
+	Day              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Trigger is a rule making the bot answer messages matching it with its own
+// probability.
+type Trigger struct {
+	ID      int64
+	GuildID string
+	// Kind is keyword, regex or alias, see the trigger package.
+	Kind        string
+	Pattern     string
+	Probability float64
+}
+
+// Store persists guild settings, their channel overrides, token usage and
+// triggers.
+type Store interface {
+	// GetGuildSetting returns ErrNotFound when the guild has no such setting.
+	GetGuildSetting(ctx context.Context, guildID, name string) (string, error)
+	// GuildSettings returns the settings of a guild by name.
+	GuildSettings(ctx context.Context, guildID string) (map[string]string, error)
+	SetGuildSetting(ctx context.Context, guildID, name, value string) error
+	DeleteGuildSetting(ctx context.Context, guildID, name string) error
+
+	// ChannelSettings returns the channel overrides of a guild by channel ID
+	// then name.
+	ChannelSettings(ctx context.Context, guildID string) (map[string]map[string]string, error)
+	SetChannelSetting(ctx context.Context, guildID, channelID, name, value string) error
+	DeleteChannelSetting(ctx context.Context, guildID, channelID, name string) error
+
+	// AddUsage adds the counts of u to the usage of its guild, channel,
+	// user, model and day.
+	AddUsage(ctx context.Context, u Usage) error
+	// ListUsage returns the usage of a guild from day since, as YYYY-MM-DD,
+	// onwards.
+	ListUsage(ctx context.Context, guildID, since string) ([]Usage, error)
+
+	// Triggers returns the triggers of a guild in the order they were added.
+	Triggers(ctx context.Context, guildID string) ([]Trigger, error)
+	// AddTrigger stores t and returns its ID.
+	AddTrigger(ctx context.Context, t Trigger) (int64, error)
+	// DeleteTrigger returns ErrNotFound when the guild has no such trigger.
+	DeleteTrigger(ctx context.Context, guildID string, id int64) error
+
+	// Flush makes pending changes durable, such as syncing an embedded
+	// replica with its remote database.
+	Flush(ctx context.Context) error
+	Close() error
+}
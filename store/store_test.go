@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/migrations"
+)
+
+// testStores returns every store to check, each migrated and empty.
+// Postgres is included when TEST_DATABASE_URL points to a database the tests
+// may write to.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	ctx := context.Background()
+	stores := map[string]Store{"memory": NewMemory()}
+
+	conn, err := sql.Open("libsql", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Run(ctx, conn, migrations.SQLite); err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	stores["libsql"] = NewLibSQL(conn)
+
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		p, _, err := NewPostgres(ctx, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores["postgres"] = p
+	}
+
+	for _, st := range stores {
+		t.Cleanup(func() { st.Close() })
+	}
+	return stores
+}
+
+// testGuild returns a guild ID no earlier run used, as a Postgres database
+// is not emptied between runs.
+func testGuild(name string) string {
+	return fmt.Sprintf("%v-%v", name, time.Now().UnixNano())
+}
+
+func TestGuildSettings(t *testing.T) {
+	ctx := context.Background()
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			guild, other := testGuild("g"), testGuild("other")
+
+			if _, err := st.GetGuildSetting(ctx, guild, "enabled"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v, want ErrNotFound", err)
+			}
+			for _, value := range []string{"off", "on"} {
+				if err := st.SetGuildSetting(ctx, guild, "enabled", value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := st.SetGuildSetting(ctx, guild, "threshold", "0.5"); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.SetGuildSetting(ctx, other, "enabled", "off"); err != nil {
+				t.Fatal(err)
+			}
+
+			if value, err := st.GetGuildSetting(ctx, guild, "enabled"); err != nil || value != "on" {
+				t.Errorf("got %q, %v, want the last value set", value, err)
+			}
+			values, err := st.GuildSettings(ctx, guild)
+			want := map[string]string{"enabled": "on", "threshold": "0.5"}
+			if err != nil || !reflect.DeepEqual(values, want) {
+				t.Errorf("got %v, %v, want %v", values, err, want)
+			}
+
+			if err := st.DeleteGuildSetting(ctx, guild, "enabled"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := st.GetGuildSetting(ctx, guild, "enabled"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v after deleting, want ErrNotFound", err)
+			}
+			if value, err := st.GetGuildSetting(ctx, other, "enabled"); err != nil || value != "off" {
+				t.Errorf("deleting changed another guild: %q, %v", value, err)
+			}
+			if values, err := st.GuildSettings(ctx, testGuild("empty")); err != nil || len(values) != 0 {
+				t.Errorf("got %v, %v for a guild without settings", values, err)
+			}
+		})
+	}
+}
+
+func TestChannelSettings(t *testing.T) {
+	ctx := context.Background()
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			guild := testGuild("g")
+			c1, c2 := guild+"-c1", guild+"-c2"
+
+			for _, value := range []string{"a", "b"} {
+				if err := st.SetChannelSetting(ctx, guild, c1, "model", value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := st.SetChannelSetting(ctx, guild, c1, "threshold", "1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.SetChannelSetting(ctx, guild, c2, "model", "c"); err != nil {
+				t.Fatal(err)
+			}
+
+			values, err := st.ChannelSettings(ctx, guild)
+			want := map[string]map[string]string{
+				c1: {"model": "b", "threshold": "1"},
+				c2: {"model": "c"},
+			}
+			if err != nil || !reflect.DeepEqual(values, want) {
+				t.Errorf("got %v, %v, want %v", values, err, want)
+			}
+
+			if err := st.DeleteChannelSetting(ctx, guild, c2, "model"); err != nil {
+				t.Fatal(err)
+			}
+			values, err = st.ChannelSettings(ctx, guild)
+			if err != nil || values[c2]["model"] != "" || values[c1]["model"] != "b" {
+				t.Errorf("got %v, %v after deleting", values, err)
+			}
+		})
+	}
+}
+
+// sortUsage orders rows the same way whatever the store returned.
+func sortUsage(rows []Usage) {
+	sort.Slice(rows, func(i, j int) bool {
+		return fmt.Sprint(rows[i]) < fmt.Sprint(rows[j])
+	})
+}
+
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			guild := testGuild("g")
+			row := func(day, model string, prompt int) Usage {
+				return Usage{
+					GuildID: guild, ChannelID: "c", UserID: "u", Provider: "groq", Model: model,
+					Day: day, Requests: 1, PromptTokens: prompt, CompletionTokens: 1,
+				}
+			}
+			for _, u := range []Usage{
+				row("2026-09-30", "a", 10),
+				row("2026-10-01", "a", 20),
+				row("2026-10-01", "a", 30),
+				row("2026-10-01", "b", 40),
+				row("2026-10-02", "a", 50),
+				{GuildID: testGuild("other"), Day: "2026-10-01", Requests: 1},
+			} {
+				if err := st.AddUsage(ctx, u); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rows, err := st.ListUsage(ctx, guild, "2026-10-01")
+			if err != nil {
+				t.Fatal(err)
+			}
+			summed := row("2026-10-01", "a", 50)
+			summed.Requests, summed.CompletionTokens = 2, 2
+			want := []Usage{summed, row("2026-10-01", "b", 40), row("2026-10-02", "a", 50)}
+			sortUsage(rows)
+			sortUsage(want)
+			// Days come back as YYYY-MM-DD, which libsql reads as a time.
+			if !reflect.DeepEqual(rows, want) {
+				t.Errorf("got %+v, want %+v", rows, want)
+			}
+
+			if rows, err := st.ListUsage(ctx, guild, "2026-11-01"); err != nil || len(rows) != 0 {
+				t.Errorf("got %+v, %v for a later month", rows, err)
+			}
+		})
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	ctx := context.Background()
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			guild, other := testGuild("g"), testGuild("other")
+
+			added := []Trigger{
+				{GuildID: guild, Kind: "keyword", Pattern: "pizza", Probability: 0.5},
+				{GuildID: guild, Kind: "regex", Pattern: `\bhi\b`, Probability: 1},
+			}
+			for i := range added {
+				id, err := st.AddTrigger(ctx, added[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i > 0 && id <= added[i-1].ID {
+					t.Errorf("got ID %v after %v", id, added[i-1].ID)
+				}
+				added[i].ID = id
+			}
+			otherID, err := st.AddTrigger(ctx, Trigger{GuildID: other, Kind: "alias", Pattern: "bot", Probability: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			triggers, err := st.Triggers(ctx, guild)
+			if err != nil || !reflect.DeepEqual(triggers, added) {
+				t.Errorf("got %+v, %v, want %+v", triggers, err, added)
+			}
+
+			if err := st.DeleteTrigger(ctx, guild, otherID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleted the trigger of another guild: %v", err)
+			}
+			if err := st.DeleteTrigger(ctx, guild, added[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.DeleteTrigger(ctx, guild, added[0].ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v deleting twice, want ErrNotFound", err)
+			}
+			triggers, err = st.Triggers(ctx, guild)
+			if err != nil || !reflect.DeepEqual(triggers, added[1:]) {
+				t.Errorf("got %+v, %v after deleting", triggers, err)
+			}
+			if triggers, err := st.Triggers(ctx, testGuild("empty")); err != nil || len(triggers) != 0 {
+				t.Errorf("got %+v, %v for a guild without triggers", triggers, err)
+			}
+		})
+	}
+}
+
+func TestFlush(t *testing.T) {
+	for name, st := range testStores(t) {
+		if err := st.Flush(context.Background()); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+}
+
+func TestLibSQLDay(t *testing.T) {
+	for day, want := range map[string]string{
+		"2026-10-01":           "2026-10-01",
+		"2026-10-01T00:00:00Z": "2026-10-01",
+	} {
+		if got := libsqlDay(day); got != want {
+			t.Errorf("libsqlDay(%q) = %q, want %q", day, got, want)
+		}
+	}
+}
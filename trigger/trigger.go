@@ -0,0 +1,191 @@
+// Package trigger matches messages against the trigger rules of a guild,
+// which make the bot answer them with their own probability.
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"polynux/disgoroq/store"
+)
+
+const (
+	// Keyword matches a word or phrase, ignoring case.
+	Keyword = "keyword"
+	// Regex matches a regular expression in RE2 syntax.
+	Regex = "regex"
+	// Alias matches a name the bot answers to, like a keyword.
+	Alias = "alias"
+)
+
+// Kinds lists the kinds of triggers.
+var Kinds = []string{Keyword, Regex, Alias}
+
+const (
+	// MaxPerGuild bounds the triggers of a guild, so matching stays cheap
+	// and /trigger list fits in a message.
+	MaxPerGuild = 25
+	// MaxPatternLength bounds the length of a pattern, in characters.
+	MaxPatternLength = 100
+)
+
+var ErrInvalid = errors.New("invalid trigger")
+
+// rule is a trigger compiled for matching.
+type rule struct {
+	store.Trigger
+	re *regexp.Regexp
+}
+
+// compile checks t and prepares it for matching.
+func compile(t store.Trigger) (rule, error) {
+	if t.Probability < 0 || t.Probability > 1 {
+		return rule{}, fmt.Errorf("%w: probability must be between 0 and 1", ErrInvalid)
+	}
+	if len([]rune(t.Pattern)) > MaxPatternLength {
+		return rule{}, fmt.Errorf("%w: pattern must be at most %v characters", ErrInvalid, MaxPatternLength)
+	}
+
+	switch t.Kind {
+	case Keyword, Alias:
+		if strings.TrimSpace(t.Pattern) == "" {
+			return rule{}, fmt.Errorf("%w: a %v needs a pattern", ErrInvalid, t.Kind)
+		}
+		// Whole words only, so "bot" does not match "robot" or "rébot": \W
+		// would take accented letters for word boundaries.
+		re := regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(strings.TrimSpace(t.Pattern)) + `($|[^\p{L}\p{N}_])`)
+		return rule{t, re}, nil
+	case Regex:
+		re, err := regexp.Compile(t.Pattern)
+		if err != nil || t.Pattern == "" {
+			return rule{}, fmt.Errorf("%w: %q is not a regular expression", ErrInvalid, t.Pattern)
+		}
+		return rule{t, re}, nil
+	default:
+		return rule{}, fmt.Errorf("%w: kind must be one of %v", ErrInvalid, strings.Join(Kinds, ", "))
+	}
+}
+
+// guildRules is the compiled triggers of a guild.
+type guildRules struct {
+	rules  []rule
+	loaded time.Time
+}
+
+// Triggers stores the triggers of each guild and caches them compiled. They
+// are reloaded after ttl so that changes made by other processes sharing the
+// store show up.
+type Triggers struct {
+	store store.Store
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	guilds map[string]*guildRules
+}
+
+func New(st store.Store, ttl time.Duration) *Triggers {
+	return &Triggers{
+		store:  st,
+		ttl:    ttl,
+		guilds: map[string]*guildRules{},
+	}
+}
+
+func (t *Triggers) rules(ctx context.Context, guildID string) ([]rule, error) {
+	t.mu.Lock()
+	g, ok := t.guilds[guildID]
+	t.mu.Unlock()
+	if ok && time.Since(g.loaded) <= t.ttl {
+		return g.rules, nil
+	}
+
+	triggers, err := t.store.Triggers(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	g = &guildRules{loaded: time.Now()}
+	for _, trigger := range triggers {
+		r, err := compile(trigger)
+		if err != nil {
+			// Stored before a rule changed, or by hand: skip it.
+			continue
+		}
+		g.rules = append(g.rules, r)
+	}
+
+	t.mu.Lock()
+	t.guilds[guildID] = g
+	t.mu.Unlock()
+	return g.rules, nil
+}
+
+// Match returns the trigger of a guild matching content with the highest
+// probability, and false when none matches.
+func (t *Triggers) Match(ctx context.Context, guildID, content string) (store.Trigger, bool) {
+	rules, err := t.rules(ctx, guildID)
+	if err != nil {
+		log.Printf("guild %v: error loading triggers, %v", guildID, err)
+		return store.Trigger{}, false
+	}
+
+	var best store.Trigger
+	found := false
+	for _, r := range rules {
+		if r.re.MatchString(content) && (!found || r.Probability > best.Probability) {
+			best, found = r.Trigger, true
+		}
+	}
+	return best, found
+}
+
+// List returns the triggers of a guild in the order they were added.
+func (t *Triggers) List(ctx context.Context, guildID string) ([]store.Trigger, error) {
+	return t.store.Triggers(ctx, guildID)
+}
+
+// Add validates and stores a trigger, returning it with its ID.
+func (t *Triggers) Add(ctx context.Context, trigger store.Trigger) (store.Trigger, error) {
+	if trigger.Kind == Keyword || trigger.Kind == Alias {
+		trigger.Pattern = strings.TrimSpace(trigger.Pattern)
+	}
+	if _, err := compile(trigger); err != nil {
+		return store.Trigger{}, err
+	}
+
+	existing, err := t.store.Triggers(ctx, trigger.GuildID)
+	if err != nil {
+		return store.Trigger{}, err
+	}
+	if len(existing) >= MaxPerGuild {
+		return store.Trigger{}, fmt.Errorf("%w: a server can have at most %v triggers", ErrInvalid, MaxPerGuild)
+	}
+
+	trigger.ID, err = t.store.AddTrigger(ctx, trigger)
+	if err != nil {
+		return store.Trigger{}, err
+	}
+	t.invalidate(trigger.GuildID)
+	return trigger, nil
+}
+
+// Remove deletes a trigger, returning store.ErrNotFound when the guild has
+// no such trigger.
+func (t *Triggers) Remove(ctx context.Context, guildID string, id int64) error {
+	if err := t.store.DeleteTrigger(ctx, guildID, id); err != nil {
+		return err
+	}
+	t.invalidate(guildID)
+	return nil
+}
+
+func (t *Triggers) invalidate(guildID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.guilds, guildID)
+}
@@ -0,0 +1,100 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/store"
+)
+
+func TestMatch(t *testing.T) {
+	ctx := context.Background()
+	triggers := New(store.NewMemory(), time.Minute)
+	for _, trigger := range []store.Trigger{
+		{Kind: Keyword, Pattern: "pizza", Probability: 0.5},
+		{Kind: Alias, Pattern: " Groqy ", Probability: 1},
+		{Kind: Regex, Pattern: `(?i)\bhow (do|can) i\b`, Probability: 0.8},
+		{Kind: Keyword, Pattern: "bot", Probability: 0.3},
+	} {
+		trigger.GuildID = "g"
+		if _, err := triggers.Add(ctx, trigger); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		content string
+		want    float64
+		match   bool
+	}{
+		{"who wants PIZZA?", 0.5, true},
+		{"pizzas are overrated", 0, false},
+		{"groqy, tell a joke", 1, true},
+		{"How can I exit vim", 0.8, true},
+		{"pizza, how do i make one?", 0.8, true},
+		{"nothing to see", 0, false},
+		{"un rébot", 0, false},
+		{"botté", 0, false},
+		{"le bot répond", 0.3, true},
+		{"à bot!", 0.3, true},
+	}
+	for _, tt := range tests {
+		got, ok := triggers.Match(ctx, "g", tt.content)
+		if ok != tt.match || got.Probability != tt.want {
+			t.Errorf("Match(%q) = %+v, %v", tt.content, got, ok)
+		}
+	}
+
+	if _, ok := triggers.Match(ctx, "other", "pizza"); ok {
+		t.Error("a trigger matched in another guild")
+	}
+}
+
+func TestAddInvalid(t *testing.T) {
+	ctx := context.Background()
+	triggers := New(store.NewMemory(), time.Minute)
+	for _, trigger := range []store.Trigger{
+		{Kind: Keyword, Pattern: "  ", Probability: 1},
+		{Kind: Regex, Pattern: "(unclosed", Probability: 1},
+		{Kind: "reply", Probability: 1},
+		{Kind: "emoji", Pattern: "x", Probability: 1},
+		{Kind: Keyword, Pattern: "x", Probability: 1.5},
+	} {
+		if _, err := triggers.Add(ctx, trigger); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Add(%+v) error %v, want ErrInvalid", trigger, err)
+		}
+	}
+
+	for n := 0; n < MaxPerGuild; n++ {
+		if _, err := triggers.Add(ctx, store.Trigger{GuildID: "g", Kind: Keyword, Pattern: "x", Probability: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := triggers.Add(ctx, store.Trigger{GuildID: "g", Kind: Keyword, Pattern: "x", Probability: 1}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("added more than %v triggers, %v", MaxPerGuild, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	triggers := New(store.NewMemory(), time.Minute)
+	added, err := triggers.Add(ctx, store.Trigger{GuildID: "g", Kind: Keyword, Pattern: "pizza", Probability: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := triggers.Match(ctx, "g", "pizza"); !ok {
+		t.Fatal("the trigger did not match")
+	}
+
+	if err := triggers.Remove(ctx, "other", added.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("removed a trigger of another guild, %v", err)
+	}
+	if err := triggers.Remove(ctx, "g", added.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := triggers.Match(ctx, "g", "pizza"); ok {
+		t.Error("a removed trigger matched")
+	}
+}
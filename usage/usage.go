@@ -0,0 +1,209 @@
+// Package usage accounts for the tokens each guild spends on completions and
+// enforces quotas on them.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"polynux/disgoroq/provider"
+	"polynux/disgoroq/store"
+)
+
+// Day returns the UTC date of t as stored in usage rows, YYYY-MM-DD.
+func Day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// MonthStart returns the first UTC day of the month of t, as YYYY-MM-DD.
+func MonthStart(t time.Time) string {
+	return t.UTC().Format("2006-01") + "-01"
+}
+
+// Totals sums the usage of completions.
+type Totals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	// Cost is estimated in US dollars from the model prices. Models without
+	// a known price cost nothing.
+	Cost float64
+}
+
+// Of returns the totals of a usage row.
+func Of(u store.Usage) Totals {
+	model, _ := provider.LookupModel(u.Provider, u.Model)
+	return Totals{
+		Requests:         u.Requests,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Cost:             model.Cost(u.PromptTokens, u.CompletionTokens),
+	}
+}
+
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+func (t *Totals) Add(other Totals) {
+	t.Requests += other.Requests
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.Cost += other.Cost
+}
+
+// Group sums rows by the key returned for each of them.
+func Group(rows []store.Usage, key func(store.Usage) string) map[string]Totals {
+	groups := map[string]Totals{}
+	for _, row := range rows {
+		t := groups[key(row)]
+		t.Add(Of(row))
+		groups[key(row)] = t
+	}
+	return groups
+}
+
+// Quota caps the usage of a guild per UTC day and month. Zero fields do not
+// limit.
+type Quota struct {
+	DailyTokens   int
+	MonthlyTokens int
+	DailyCost     float64
+	MonthlyCost   float64
+}
+
+// Min returns the tightest of the limits of q and other.
+func (q Quota) Min(other Quota) Quota {
+	return Quota{
+		DailyTokens:   minLimit(q.DailyTokens, other.DailyTokens),
+		MonthlyTokens: minLimit(q.MonthlyTokens, other.MonthlyTokens),
+		DailyCost:     minLimit(q.DailyCost, other.DailyCost),
+		MonthlyCost:   minLimit(q.MonthlyCost, other.MonthlyCost),
+	}
+}
+
+func minLimit[T int | float64](a, b T) T {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Exceeded describes the first limit reached by the usage of the day and
+// of the month, and returns "" when there is none.
+func (q Quota) Exceeded(day, month Totals) string {
+	switch {
+	case q.DailyTokens > 0 && day.Tokens() >= q.DailyTokens:
+		return fmt.Sprintf("daily quota of %v tokens", q.DailyTokens)
+	case q.MonthlyTokens > 0 && month.Tokens() >= q.MonthlyTokens:
+		return fmt.Sprintf("monthly quota of %v tokens", q.MonthlyTokens)
+	case q.DailyCost > 0 && day.Cost >= q.DailyCost:
+		return fmt.Sprintf("daily quota of $%v", q.DailyCost)
+	case q.MonthlyCost > 0 && month.Cost >= q.MonthlyCost:
+		return fmt.Sprintf("monthly quota of $%v", q.MonthlyCost)
+	}
+	return ""
+}
+
+// String lists the limits of q, or "none".
+func (q Quota) String() string {
+	var limits []string
+	if q.DailyTokens > 0 {
+		limits = append(limits, fmt.Sprintf("%v tokens a day", q.DailyTokens))
+	}
+	if q.MonthlyTokens > 0 {
+		limits = append(limits, fmt.Sprintf("%v tokens a month", q.MonthlyTokens))
+	}
+	if q.DailyCost > 0 {
+		limits = append(limits, fmt.Sprintf("$%v a day", q.DailyCost))
+	}
+	if q.MonthlyCost > 0 {
+		limits = append(limits, fmt.Sprintf("$%v a month", q.MonthlyCost))
+	}
+	if len(limits) == 0 {
+		return "none"
+	}
+	return strings.Join(limits, ", ")
+}
+
+// guildTotals is the usage of a guild in month, by day.
+type guildTotals struct {
+	month  string
+	days   map[string]Totals
+	loaded time.Time
+}
+
+// Tracker records usage in a store and keeps the totals of each guild for
+// the current month in memory. They are reloaded after ttl so that usage
+// recorded by other processes sharing the store is accounted for.
+type Tracker struct {
+	store store.Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu     sync.Mutex
+	guilds map[string]*guildTotals
+}
+
+func NewTracker(st store.Store, ttl time.Duration) *Tracker {
+	return &Tracker{
+		store:  st,
+		ttl:    ttl,
+		now:    time.Now,
+		guilds: map[string]*guildTotals{},
+	}
+}
+
+// Record stores u, dated today, and adds it to the totals of its guild.
+func (t *Tracker) Record(ctx context.Context, u store.Usage) error {
+	u.Day = Day(t.now())
+	if u.Requests == 0 {
+		u.Requests = 1
+	}
+	if err := t.store.AddUsage(ctx, u); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if g, ok := t.guilds[u.GuildID]; ok && strings.HasPrefix(u.Day, g.month) {
+		day := g.days[u.Day]
+		day.Add(Of(u))
+		g.days[u.Day] = day
+	}
+	return nil
+}
+
+// Totals returns the usage of a guild today and this month.
+func (t *Tracker) Totals(ctx context.Context, guildID string) (day, month Totals, err error) {
+	now := t.now()
+	monthStart := MonthStart(now)
+
+	t.mu.Lock()
+	g, ok := t.guilds[guildID]
+	t.mu.Unlock()
+	if !ok || g.month != monthStart[:7] || now.Sub(g.loaded) > t.ttl {
+		rows, err := t.store.ListUsage(ctx, guildID, monthStart)
+		if err != nil {
+			return Totals{}, Totals{}, err
+		}
+		g = &guildTotals{
+			month:  monthStart[:7],
+			days:   Group(rows, func(u store.Usage) string { return u.Day }),
+			loaded: now,
+		}
+		t.mu.Lock()
+		t.guilds[guildID] = g
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, totals := range g.days {
+		month.Add(totals)
+	}
+	return g.days[Day(now)], month, nil
+}
@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"polynux/disgoroq/store"
+)
+
+func TestQuotaMin(t *testing.T) {
+	operator := Quota{DailyTokens: 1000, MonthlyCost: 5}
+	guild := Quota{DailyTokens: 2000, MonthlyTokens: 10000, MonthlyCost: 1}
+
+	want := Quota{DailyTokens: 1000, MonthlyTokens: 10000, MonthlyCost: 1}
+	if got := operator.Min(guild); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	quota := Quota{DailyTokens: 100, MonthlyCost: 1}
+	tests := []struct {
+		day, month Totals
+		want       string
+	}{
+		{Totals{PromptTokens: 50}, Totals{PromptTokens: 50}, ""},
+		{Totals{PromptTokens: 60, CompletionTokens: 40}, Totals{PromptTokens: 100}, "daily quota of 100 tokens"},
+		{Totals{}, Totals{Cost: 1.5}, "monthly quota of $1"},
+	}
+	for _, tt := range tests {
+		if got := quota.Exceeded(tt.day, tt.month); got != tt.want {
+			t.Errorf("Exceeded(%+v, %+v) = %q, want %q", tt.day, tt.month, got, tt.want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	now := time.Date(2024, 9, 15, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(st, time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	// Recorded before this month, or by another process before the totals
+	// were loaded.
+	st.AddUsage(ctx, store.Usage{GuildID: "g", Day: "2024-08-31", Requests: 1, PromptTokens: 1000})
+	st.AddUsage(ctx, store.Usage{GuildID: "g", Day: "2024-09-14", Requests: 1, PromptTokens: 100})
+
+	day, month, err := tracker.Totals(ctx, "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Tokens() != 0 || month.Tokens() != 100 {
+		t.Errorf("got %+v today and %+v this month", day, month)
+	}
+
+	u := store.Usage{GuildID: "g", UserID: "alice", Provider: "groq", Model: "llama3-70b-8192", PromptTokens: 1_000_000, CompletionTokens: 1_000_000}
+	if err := tracker.Record(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	day, month, _ = tracker.Totals(ctx, "g")
+	if day.Requests != 1 || day.Tokens() != 2_000_000 || month.Tokens() != 2_000_100 {
+		t.Errorf("got %+v today and %+v this month", day, month)
+	}
+	if cost := day.Cost; cost < 1.37 || cost > 1.39 {
+		t.Errorf("cost %v, want $1.38", cost)
+	}
+
+	rows, _ := st.ListUsage(ctx, "g", "2024-09-15")
+	if len(rows) != 1 || rows[0].Day != "2024-09-15" || rows[0].Requests != 1 {
+		t.Errorf("stored %+v", rows)
+	}
+
+	now = now.AddDate(0, 1, 0)
+	if _, month, _ := tracker.Totals(ctx, "g"); month.Tokens() != 0 {
+		t.Errorf("usage carried over to the next month, %+v", month)
+	}
+}
+
+func TestQuotaString(t *testing.T) {
+	if got := (Quota{}).String(); got != "none" {
+		t.Errorf("got %q", got)
+	}
+	if got := (Quota{DailyTokens: 10, MonthlyCost: 2.5}).String(); !strings.Contains(got, "10 tokens a day") || !strings.Contains(got, "$2.5 a month") {
+		t.Errorf("got %q", got)
+	}
+}
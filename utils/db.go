@@ -11,14 +11,10 @@ import (
 	"github.com/tursodatabase/go-libsql"
 	_ "github.com/tursodatabase/go-libsql"
 
-	"polynux/disgoroq/db"
+	"polynux/disgoroq/migrations"
 )
 
-var DB *sql.DB
-var Q *db.Queries
-
-func Connect() *sql.DB {
-	dbName := "local.db"
+func Connect(dataDir string) (*sql.DB, *libsql.Connector) {
 	dbUrl := GetEnv("DB_URL")
 	dbToken := GetEnv("DB_TOKEN")
 	if dbUrl == "" {
@@ -29,13 +25,8 @@ func Connect() *sql.DB {
 		log.Fatal("DB_TOKEN is not set")
 		os.Exit(1)
 	}
-	dir, err := os.MkdirTemp("", "libsql-*")
-	if err != nil {
-		log.Fatalf("Error creating temp directory: %v", err)
-		os.Exit(1)
-	}
 
-	dbPath := filepath.Join(dir, dbName)
+	dbPath := dataFile(dataDir, "replica.db")
 
 	connector, err := libsql.NewEmbeddedReplicaConnector(dbPath, dbUrl, libsql.WithAuthToken(dbToken), libsql.WithSyncInterval(time.Minute))
 	if err != nil {
@@ -45,18 +36,11 @@ func Connect() *sql.DB {
 
 	db := sql.OpenDB(connector)
 
-	return db
+	return db, connector
 }
 
-func ConnectLocal() *sql.DB {
-	dbName := "local.db"
-	dir, err := os.MkdirTemp("", "libsql-*")
-	if err != nil {
-		log.Fatalf("Error creating temp directory: %v", err)
-		os.Exit(1)
-	}
-
-	dbPath := filepath.Join(dir, dbName)
+func ConnectLocal(dataDir string) *sql.DB {
+	dbPath := dataFile(dataDir, "local.db")
 
 	db, err := sql.Open("libsql", "file:"+dbPath)
 	if err != nil {
@@ -67,32 +51,46 @@ func ConnectLocal() *sql.DB {
 	return db
 }
 
-func InitializeDB(local bool) {
+// dataFile returns the path of name inside dataDir, creating the directory
+// if needed.
+func dataFile(dataDir, name string) string {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		log.Fatalf("Error creating data directory: %v", err)
+		os.Exit(1)
+	}
+	return filepath.Join(dataDir, name)
+}
+
+// InitializeDB opens the database kept in dataDir. Without -local, a
+// configured DB_URL makes it an embedded replica of that remote database;
+// otherwise it is a plain local SQLite file, and the returned connector is
+// nil.
+func InitializeDB(local bool, dataDir string) (*sql.DB, *libsql.Connector) {
+	if !local && GetEnv("DB_URL") == "" {
+		log.Println("DB_URL is not set, using a local database")
+		local = true
+	}
+
+	var conn *sql.DB
+	var connector *libsql.Connector
 	if !local {
-		DB = Connect()
+		conn, connector = Connect(dataDir)
 	} else {
-		DB = ConnectLocal()
+		conn = ConnectLocal(dataDir)
 	}
 
-	Q = db.New(DB)
-	CreateTables(context.Background())
+	Migrate(context.Background(), conn)
+	return conn, connector
 }
 
-func LoadSql() string {
-	sql, err := os.ReadFile("schema.sql")
-	if err != nil {
-		log.Fatalf("Error reading schema.sql: %v", err)
-		os.Exit(1)
+// Migrate brings the schema up to date and logs the migrations it applied.
+func Migrate(ctx context.Context, conn *sql.DB) {
+	applied, err := migrations.Run(ctx, conn, migrations.SQLite)
+	for _, m := range applied {
+		log.Printf("Applied migration %v_%v", m.Version, m.Name)
 	}
-	return string(sql)
-}
-
-func CreateTables(ctx context.Context) {
-	schema := LoadSql()
-
-	_, err := DB.ExecContext(ctx, schema)
 	if err != nil {
-		log.Fatalf("Error creating tables: %v", err)
+		log.Fatalf("Error migrating database: %v", err)
 		os.Exit(1)
 	}
 }